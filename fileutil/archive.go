@@ -0,0 +1,434 @@
+package fileutil
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnknownArchive is returned by Archive and Extract for unsupported formats
+var ErrUnknownArchive = errors.New("fileutil: unknown archive format")
+
+// Errors returned when extracting a tar or gzip file that exceeds the limits of
+// ExtractOptions or holds an entry that cannot be extracted
+var (
+	ErrArchiveTooLarge       = errors.New("fileutil: archive uncompressed size exceeds the limit")
+	ErrArchiveTooManyEntries = errors.New("fileutil: archive has too many entries")
+	ErrUnsupportedEntry      = errors.New("fileutil: unsupported archive entry type")
+)
+
+// ExtractOptions configures ExtractWithOptions, UnTarWithOptions, UnTarGzWithOptions
+// and GunzipWithOptions. The zero value has no limits.
+//
+// Unlike a zip file, a tar or gzip file has no index to check up front: the limits are
+// enforced while extracting, which stops at the first entry exceeding them.
+type ExtractOptions struct {
+	// MaxTotalSize limits the total uncompressed size of the extracted files, in bytes.
+	MaxTotalSize int64
+	// MaxEntries limits the number of entries of the archive.
+	MaxEntries int
+}
+
+// copy copies r to w, failing with ErrArchiveTooLarge once MaxTotalSize is exceeded.
+func (opts *ExtractOptions) copy(w io.Writer, r io.Reader) error {
+	if opts.MaxTotalSize <= 0 {
+		// #nosec G110 -- the caller chose not to limit the size
+		_, err := io.Copy(w, r)
+		return err
+	}
+
+	// Copy at most one byte past the limit to detect it
+	n, err := io.Copy(w, io.LimitReader(r, opts.MaxTotalSize+1))
+	if err == nil && n > opts.MaxTotalSize {
+		err = fmt.Errorf("%w: more than %d bytes", ErrArchiveTooLarge, opts.MaxTotalSize)
+	}
+	return err
+}
+
+const (
+	gzipPrefix  = "\x1f\x8b"
+	tarMagic    = "ustar"
+	tarMagicOff = 257
+)
+
+// Tar archives a file or directory to a tar file.
+// `dst` usually ends with ".tar".
+func Tar(dst, src string) error {
+	return writeArchive(dst, func(w io.Writer) error { return writeTar(w, src) })
+}
+
+// UnTar extracts a tar file to dst.
+func UnTar(dst, src string) error {
+	return UnTarWithOptions(dst, src, ExtractOptions{})
+}
+
+// UnTarWithOptions extracts a tar file to dst, enforcing the limits of opts.
+func UnTarWithOptions(dst, src string, opts ExtractOptions) error {
+	f, err := os.Open(src) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return readTar(dst, f, opts)
+}
+
+// TarGz archives a file or directory to a gzip compressed tar file.
+// `dst` usually ends with ".tar.gz" or ".tgz".
+func TarGz(dst, src string) error {
+	return writeArchive(dst, func(w io.Writer) error {
+		zw := gzip.NewWriter(w)
+		if err := writeTar(zw, src); err != nil {
+			return err
+		}
+		return zw.Close()
+	})
+}
+
+// UnTarGz extracts a gzip compressed tar file to dst.
+func UnTarGz(dst, src string) error {
+	return UnTarGzWithOptions(dst, src, ExtractOptions{})
+}
+
+// UnTarGzWithOptions extracts a gzip compressed tar file to dst, enforcing the limits
+// of opts.
+func UnTarGzWithOptions(dst, src string, opts ExtractOptions) error {
+	f, err := os.Open(src) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	return readTar(dst, zr, opts)
+}
+
+// Gzip compresses the file src to dst.
+// `dst` usually ends with ".gz".
+func Gzip(dst, src string) error {
+	in, err := os.Open(src) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	return writeArchive(dst, func(w io.Writer) error {
+		zw := gzip.NewWriter(w)
+		zw.Name, zw.ModTime = info.Name(), info.ModTime()
+		if _, err := io.Copy(zw, in); err != nil {
+			return err
+		}
+		return zw.Close()
+	})
+}
+
+// Gunzip decompresses the gzip file src to the file dst.
+func Gunzip(dst, src string) error {
+	return GunzipWithOptions(dst, src, ExtractOptions{})
+}
+
+// GunzipWithOptions decompresses the gzip file src to the file dst, enforcing the
+// MaxTotalSize limit of opts.
+func GunzipWithOptions(dst, src string, opts ExtractOptions) error {
+	in, err := os.Open(src) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	return writeArchive(dst, func(w io.Writer) error {
+		return opts.copy(w, zr)
+	})
+}
+
+// Archive archives the file or directory src to dst, in the format given by the
+// extension of dst: ".zip", ".tar", ".tar.gz" or ".tgz", or ".gz" for a single file.
+func Archive(dst, src string) error {
+	name := strings.ToLower(dst)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return Zip(dst, src)
+	case strings.HasSuffix(name, ".tar"):
+		return Tar(dst, src)
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return TarGz(dst, src)
+	case strings.HasSuffix(name, ".gz"):
+		return Gzip(dst, src)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownArchive, dst)
+	}
+}
+
+// Extract extracts the archive src to the directory dst. The format is detected
+// from the content of src, a zip, tar or gzip compressed tar file. A gzip file
+// that is not a tar is decompressed to dst under its original name, or the name
+// of src without ".gz".
+func Extract(dst, src string) error {
+	return ExtractWithOptions(dst, src, ExtractOptions{})
+}
+
+// ExtractWithOptions is Extract enforcing the limits of opts. A zip file is extracted
+// by UnZipWithOptions with the same limits, which fails with ErrZipTooLarge and
+// ErrZipTooManyEntries instead of their archive counterparts.
+func ExtractWithOptions(dst, src string, opts ExtractOptions) error {
+	f, err := os.Open(src) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	head, _ := br.Peek(tarMagicOff + len(tarMagic))
+	switch {
+	case bytes.HasPrefix(head, []byte(zipPreFix)):
+		return UnZipWithOptions(dst, src, UnZipOptions{
+			MaxTotalSize: opts.MaxTotalSize,
+			MaxEntries:   opts.MaxEntries,
+			PreserveMode: true,
+		})
+	case isTarHeader(head):
+		return readTar(dst, br, opts)
+	case !bytes.HasPrefix(head, []byte(gzipPrefix)):
+		return fmt.Errorf("%w: %s", ErrUnknownArchive, src)
+	}
+
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	bzr := bufio.NewReader(zr)
+	if head, _ := bzr.Peek(tarMagicOff + len(tarMagic)); isTarHeader(head) {
+		return readTar(dst, bzr, opts)
+	}
+
+	name := filepath.Base(zr.Name)
+	if zr.Name == "" || name == "." || name == ".." || name == string(filepath.Separator) {
+		name = strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	}
+	if err := os.MkdirAll(dst, 0o755); err != nil { //nolint:gosec
+		return err
+	}
+	return writeArchive(filepath.Join(dst, name), func(w io.Writer) error {
+		return opts.copy(w, bzr)
+	})
+}
+
+// isTarHeader reports whether head starts with a ustar or GNU tar header.
+func isTarHeader(head []byte) bool {
+	return len(head) >= tarMagicOff+len(tarMagic) &&
+		string(head[tarMagicOff:tarMagicOff+len(tarMagic)]) == tarMagic
+}
+
+// writeArchive creates dst and fills it with write, removing it if write fails.
+func writeArchive(dst string, write func(w io.Writer) error) error {
+	out, err := os.Create(dst) //nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(out)
+	err = write(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+	}
+	return err
+}
+
+// writeTar writes the file or directory src to w as a tar stream. Entries are named
+// relative to the parent of src, symbolic links are stored as such.
+func writeTar(w io.Writer, src string) error {
+	tw := tar.NewWriter(w)
+	base := filepath.Dir(filepath.Clean(src))
+
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return copyFileTo(tw, path)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// copyFileTo copies the content of the file to w.
+func copyFileTo(w io.Writer, file string) error {
+	f, err := os.Open(file) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// readTar extracts the tar stream r to dst, enforcing the limits of opts. Entries
+// escaping dst, by their name, as symbolic or hard links or through a symbolic link
+// extracted earlier, are rejected, and so are devices, FIFOs and other entries that
+// are not files, directories or links.
+func readTar(dst string, r io.Reader, opts ExtractOptions) error {
+	var (
+		tr      = tar.NewReader(r)
+		entries int
+		written int64
+	)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue // metadata of the following entries, not a file
+		}
+
+		entries++
+		if opts.MaxEntries > 0 && entries > opts.MaxEntries {
+			return fmt.Errorf("%w: more than %d", ErrArchiveTooManyEntries, opts.MaxEntries)
+		}
+
+		target, err := safeFilepathJoin(dst, header.Name)
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeSymlink {
+			if err := checkNoSymlink(dst, target); err != nil {
+				return fmt.Errorf("%s: %w", header.Name, err)
+			}
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, header.FileInfo().Mode().Perm()|0o700)
+		case tar.TypeReg, tar.TypeGNUSparse: // the tar reader fills in the holes of sparse files
+			var n int64
+			n, err = extractTarFile(tr, header, target, &opts, written)
+			written += n
+		case tar.TypeSymlink:
+			err = safeSymlink(dst, target, header.Linkname)
+		case tar.TypeLink:
+			err = extractTarLink(dst, target, header.Linkname)
+		default:
+			err = fmt.Errorf("%w %q", ErrUnsupportedEntry, header.Typeflag)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", header.Name, err)
+		}
+	}
+}
+
+// extractTarFile writes the current entry of tr to target, given that written bytes
+// have already been extracted, and returns the number of bytes written.
+func extractTarFile(
+	tr *tar.Reader, header *tar.Header, target string, opts *ExtractOptions, written int64,
+) (int64, error) {
+	if opts.MaxTotalSize > 0 && header.Size > opts.MaxTotalSize-written {
+		return 0, fmt.Errorf("%w: more than %d bytes", ErrArchiveTooLarge, opts.MaxTotalSize)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil { //nolint:gosec
+		return 0, err
+	}
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, header.FileInfo().Mode().Perm()) //nolint:gosec
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	// The tar reader never returns more than the size of the entry
+	n, err := io.CopyN(out, tr, header.Size)
+	if err != nil {
+		return n, err
+	}
+	if err := out.Close(); err != nil {
+		return n, err
+	}
+	return n, os.Chtimes(target, header.AccessTime, header.ModTime)
+}
+
+// extractTarLink creates target as a hard link to name, a regular file extracted by an
+// earlier entry inside dst.
+func extractTarLink(dst, target, name string) error {
+	source, err := safeFilepathJoin(dst, name)
+	if err != nil {
+		return err
+	}
+	if err := checkNoSymlink(dst, source); err != nil {
+		return err
+	}
+	info, err := os.Lstat(source)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("(zipslip) hard link target is not a regular file %q", name)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil { //nolint:gosec
+		return err
+	}
+	_ = os.Remove(target)
+	return os.Link(source, target)
+}
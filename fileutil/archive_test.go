@@ -0,0 +1,361 @@
+package fileutil
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTestTree creates a small directory tree with a symbolic link and returns its root.
+func writeTestTree(t *testing.T) string {
+	t.Helper()
+
+	root := filepath.Join(t.TempDir(), "tree")
+	files := map[string]string{
+		"a.txt":         "alpha",
+		"sub/b.txt":     "bravo",
+		"sub/deep/c.go": "package c",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+	if err := os.Symlink("a.txt", filepath.Join(root, "link")); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+	return root
+}
+
+func checkTestTree(t *testing.T, root string) {
+	t.Helper()
+
+	want := []string{"a.txt", "link", "sub/b.txt", "sub/deep/c.go"}
+	if got := listFiles(t, root); !reflect.DeepEqual(got, want) {
+		t.Fatalf("extracted %v, want %v", got, want)
+	}
+	if content, err := os.ReadFile(filepath.Join(root, "sub", "b.txt")); err != nil || string(content) != "bravo" {
+		t.Errorf("sub/b.txt = %q, %v, want \"bravo\"", content, err)
+	}
+	if link, err := os.Readlink(filepath.Join(root, "link")); err != nil || link != "a.txt" {
+		t.Errorf("link = %q, %v, want a symbolic link to a.txt", link, err)
+	}
+	if info, err := os.Stat(filepath.Join(root, "a.txt")); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("a.txt mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+}
+
+func TestTar(t *testing.T) {
+	src := writeTestTree(t)
+
+	tests := []struct {
+		name    string
+		archive func(dst, src string) error
+		extract func(dst, src string) error
+		file    string
+	}{
+		{"tar", Tar, UnTar, "tree.tar"},
+		{"tar.gz", TarGz, UnTarGz, "tree.tar.gz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), tt.file)
+			if err := tt.archive(archive, src); err != nil {
+				t.Fatalf("archive error = %v", err)
+			}
+
+			dst := t.TempDir()
+			if err := tt.extract(dst, archive); err != nil {
+				t.Fatalf("extract error = %v", err)
+			}
+			checkTestTree(t, filepath.Join(dst, "tree"))
+		})
+	}
+}
+
+func TestGzip(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "data.txt")
+	content := bytes.Repeat([]byte("gzip me "), 1000)
+	if err := os.WriteFile(src, content, 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	gz := filepath.Join(dir, "data.txt.gz")
+	if err := Gzip(gz, src); err != nil {
+		t.Fatalf("Gzip() error = %v", err)
+	}
+	out := filepath.Join(dir, "out.txt")
+	if err := Gunzip(out, gz); err != nil {
+		t.Fatalf("Gunzip() error = %v", err)
+	}
+	if got, err := os.ReadFile(out); err != nil || !bytes.Equal(got, content) {
+		t.Errorf("Gunzip() content differs, err = %v", err)
+	}
+
+	if err := Gunzip(filepath.Join(dir, "bad.txt"), src); err == nil {
+		t.Error("Gunzip() expected error for a non gzip file")
+	}
+	if IsExist(filepath.Join(dir, "bad.txt")) {
+		t.Error("Gunzip() should not leave a partial file")
+	}
+}
+
+func TestArchiveExtract(t *testing.T) {
+	src := writeTestTree(t)
+
+	for _, name := range []string{"tree.tar", "tree.tar.gz", "tree.tgz", "tree.zip"} {
+		t.Run(name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), name)
+			if err := Archive(archive, src); err != nil {
+				t.Fatalf("Archive() error = %v", err)
+			}
+
+			// Detection must not depend on the extension
+			renamed := filepath.Join(t.TempDir(), "archive.bin")
+			if err := os.Rename(archive, renamed); err != nil {
+				t.Fatalf("Rename() error = %v", err)
+			}
+
+			dst := t.TempDir()
+			if err := Extract(dst, renamed); err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			if name == "tree.zip" {
+				// Zip stores the content of the directory and follows links
+				want := []string{"a.txt", "link", "sub/b.txt", "sub/deep/c.go"}
+				if got := listFiles(t, dst); !reflect.DeepEqual(got, want) {
+					t.Errorf("Extract() extracted %v", got)
+				}
+				return
+			}
+			checkTestTree(t, filepath.Join(dst, "tree"))
+		})
+	}
+
+	t.Run("gz", func(t *testing.T) {
+		gz := filepath.Join(t.TempDir(), "a.txt.gz")
+		if err := Archive(gz, filepath.Join(src, "a.txt")); err != nil {
+			t.Fatalf("Archive() error = %v", err)
+		}
+		dst := t.TempDir()
+		if err := Extract(dst, gz); err != nil {
+			t.Fatalf("Extract() error = %v", err)
+		}
+		if content, err := os.ReadFile(filepath.Join(dst, "a.txt")); err != nil || string(content) != "alpha" {
+			t.Errorf("Extract() a.txt = %q, %v", content, err)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		if err := Archive(filepath.Join(t.TempDir(), "tree.rar"), src); !errors.Is(err, ErrUnknownArchive) {
+			t.Errorf("Archive() error = %v, want ErrUnknownArchive", err)
+		}
+		if err := Extract(t.TempDir(), filepath.Join(src, "a.txt")); !errors.Is(err, ErrUnknownArchive) {
+			t.Errorf("Extract() error = %v, want ErrUnknownArchive", err)
+		}
+	})
+}
+
+func TestUnTar_Unsafe(t *testing.T) {
+	tests := []struct {
+		name   string
+		header tar.Header
+	}{
+		{"parent path", tar.Header{Name: "../evil.txt", Typeflag: tar.TypeReg, Mode: 0o644, Size: 1}},
+		{"absolute symlink", tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}},
+		{"escaping symlink", tar.Header{Name: "a/link", Typeflag: tar.TypeSymlink, Linkname: "../../x"}},
+		{"escaping hard link", tar.Header{Name: "link", Typeflag: tar.TypeLink, Linkname: "../x"}},
+		{"device", tar.Header{Name: "null", Typeflag: tar.TypeChar, Devmajor: 1, Devminor: 3}},
+		{"fifo", tar.Header{Name: "fifo", Typeflag: tar.TypeFifo}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			if err := tw.WriteHeader(&tt.header); err != nil {
+				t.Fatalf("WriteHeader() error = %v", err)
+			}
+			if tt.header.Size > 0 {
+				_, _ = tw.Write([]byte("x"))
+			}
+			if err := tw.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			src := filepath.Join(t.TempDir(), "evil.tar")
+			if err := os.WriteFile(src, buf.Bytes(), 0o600); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			dst := t.TempDir()
+			if err := UnTar(dst, src); err == nil {
+				t.Error("UnTar() expected error for an unsafe entry")
+			}
+			if files := listFiles(t, dst); len(files) != 0 {
+				t.Errorf("UnTar() extracted %v", files)
+			}
+		})
+	}
+}
+
+func TestUnTar_SymlinkChain(t *testing.T) {
+	// s -> dst, s/esc -> dst/.. : the file entry would land next to dst
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	headers := []tar.Header{
+		{Name: "s", Typeflag: tar.TypeSymlink, Linkname: "."},
+		{Name: "s/esc", Typeflag: tar.TypeSymlink, Linkname: ".."},
+		{Name: "s/esc/pwned.txt", Typeflag: tar.TypeReg, Mode: 0o644, Size: 1},
+	}
+	for i := range headers {
+		if err := tw.WriteHeader(&headers[i]); err != nil {
+			t.Fatalf("WriteHeader() error = %v", err)
+		}
+	}
+	_, _ = tw.Write([]byte("x"))
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	tarFile := filepath.Join(dir, "evil.tar")
+	if err := os.WriteFile(tarFile, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	var gzBuf bytes.Buffer
+	zw := gzip.NewWriter(&gzBuf)
+	_, _ = zw.Write(buf.Bytes())
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	tarGzFile := filepath.Join(dir, "evil.tar.gz")
+	if err := os.WriteFile(tarGzFile, gzBuf.Bytes(), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		extract func(dst, src string) error
+		src     string
+	}{
+		{"UnTar", UnTar, tarFile},
+		{"UnTarGz", UnTarGz, tarGzFile},
+		{"Extract tar", Extract, tarFile},
+		{"Extract tar.gz", Extract, tarGzFile},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := t.TempDir()
+			dst := filepath.Join(parent, "dst")
+			if err := tt.extract(dst, tt.src); err == nil {
+				t.Errorf("%s() expected error for an entry under a symlink", tt.name)
+			}
+			if _, err := os.Lstat(filepath.Join(parent, "pwned.txt")); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("%s() wrote outside dst: %v", tt.name, err)
+			}
+		})
+	}
+}
+
+// writeTestTar writes a tar file holding files, in order, and returns its path.
+func writeTestTar(t *testing.T, files ...[2]string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		header := &tar.Header{Name: f[0], Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(f[1]))}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("WriteHeader() error = %v", err)
+		}
+		_, _ = tw.Write([]byte(f[1]))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	src := filepath.Join(t.TempDir(), "test.tar")
+	if err := os.WriteFile(src, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return src
+}
+
+func TestUnTar_HardLink(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	_ = tw.WriteHeader(&tar.Header{Name: "a.txt", Typeflag: tar.TypeReg, Mode: 0o644, Size: 5})
+	_, _ = tw.Write([]byte("alpha"))
+	_ = tw.WriteHeader(&tar.Header{Name: "sub/b.txt", Typeflag: tar.TypeLink, Linkname: "a.txt"})
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	src := filepath.Join(t.TempDir(), "links.tar")
+	if err := os.WriteFile(src, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	dst := t.TempDir()
+	if err := UnTar(dst, src); err != nil {
+		t.Fatalf("UnTar() error = %v", err)
+	}
+	if content, err := os.ReadFile(filepath.Join(dst, "sub", "b.txt")); err != nil || string(content) != "alpha" {
+		t.Errorf("sub/b.txt = %q, %v, want \"alpha\"", content, err)
+	}
+}
+
+func TestExtractWithOptions_Limits(t *testing.T) {
+	src := writeTestTar(t, [2]string{"a.txt", "0123456789"}, [2]string{"b.txt", "0123456789"},
+		[2]string{"c.txt", "0123456789"})
+
+	tests := []struct {
+		name string
+		opts ExtractOptions
+		want error
+	}{
+		{"no limits", ExtractOptions{}, nil},
+		{"within limits", ExtractOptions{MaxTotalSize: 30, MaxEntries: 3}, nil},
+		{"too many entries", ExtractOptions{MaxEntries: 2}, ErrArchiveTooManyEntries},
+		{"too large", ExtractOptions{MaxTotalSize: 25}, ErrArchiveTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ExtractWithOptions(t.TempDir(), src, tt.opts); !errors.Is(err, tt.want) {
+				t.Errorf("ExtractWithOptions() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("gzip", func(t *testing.T) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "data.txt")
+		if err := os.WriteFile(file, bytes.Repeat([]byte("x"), 100), 0o600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		gz := filepath.Join(dir, "data.txt.gz")
+		if err := Gzip(gz, file); err != nil {
+			t.Fatalf("Gzip() error = %v", err)
+		}
+
+		out := filepath.Join(dir, "out.txt")
+		if err := GunzipWithOptions(out, gz, ExtractOptions{MaxTotalSize: 50}); !errors.Is(err, ErrArchiveTooLarge) {
+			t.Errorf("GunzipWithOptions() error = %v, want ErrArchiveTooLarge", err)
+		}
+		if _, err := os.Stat(out); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("GunzipWithOptions() left a partial file: %v", err)
+		}
+		if err := GunzipWithOptions(out, gz, ExtractOptions{MaxTotalSize: 100}); err != nil {
+			t.Errorf("GunzipWithOptions() error = %v", err)
+		}
+	})
+}
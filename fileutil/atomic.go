@@ -0,0 +1,190 @@
+package fileutil
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// ErrAtomicWriterClosed is returned when using an AtomicWriter after Commit or Close
+var ErrAtomicWriterClosed = errors.New("fileutil: atomic writer already closed")
+
+// AtomicWriter writes a file atomically: the content goes to a temporary file in the
+// same directory, which only replaces the target on Commit, once synced to disk. Readers
+// and crashes see either the previous content or the new one, never a partial file.
+//
+// Close discards the content if Commit was not called, so it can always be deferred:
+//
+//	w, err := fileutil.NewAtomicWriter(path, 0o644)
+//	if err != nil {
+//		return err
+//	}
+//	defer w.Close()
+//	if _, err := w.Write(data); err != nil {
+//		return err
+//	}
+//	return w.Commit()
+type AtomicWriter struct {
+	path string // target, symbolic links resolved
+	perm os.FileMode
+	tmp  *os.File // nil once committed or closed
+}
+
+// NewAtomicWriter starts writing the file path, which gets the permissions perm once
+// committed. If path is a symbolic link, the file it points to is replaced.
+func NewAtomicWriter(path string, perm os.FileMode) (*AtomicWriter, error) {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicWriter{path: path, perm: perm, tmp: tmp}, nil
+}
+
+// Name returns the path of the file being written.
+func (w *AtomicWriter) Name() string { return w.path }
+
+// Write writes p to the temporary file.
+func (w *AtomicWriter) Write(p []byte) (int, error) {
+	if w.tmp == nil {
+		return 0, ErrAtomicWriterClosed
+	}
+	return w.tmp.Write(p)
+}
+
+// WriteString writes s to the temporary file.
+func (w *AtomicWriter) WriteString(s string) (int, error) {
+	if w.tmp == nil {
+		return 0, ErrAtomicWriterClosed
+	}
+	return w.tmp.WriteString(s)
+}
+
+// Commit syncs the content to disk and renames it over the target, then syncs the
+// directory so the rename itself survives a crash. The content is discarded on error.
+func (w *AtomicWriter) Commit() error {
+	if w.tmp == nil {
+		return ErrAtomicWriterClosed
+	}
+	tmp := w.tmp
+	w.tmp = nil
+
+	err := tmp.Chmod(w.perm)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), w.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return SyncDir(filepath.Dir(w.path))
+}
+
+// Close discards the content if it was not committed. It is a no-op otherwise.
+func (w *AtomicWriter) Close() error {
+	if w.tmp == nil {
+		return nil
+	}
+	tmp := w.tmp
+	w.tmp = nil
+
+	err := tmp.Close()
+	if removeErr := os.Remove(tmp.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// WriteFileAtomic writes data to the file path atomically, see AtomicWriter.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return writeAtomic(path, perm, false, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// WriteStringToFileAtomic is WriteStringToFile writing atomically, see AtomicWriter.
+// Appending copies the existing content first.
+func WriteStringToFileAtomic(file, content string, appendToTail bool) error {
+	return writeAtomic(file, 0o644, appendToTail, func(w io.Writer) error {
+		_, err := io.WriteString(w, content)
+		return err
+	})
+}
+
+// WriteBytesToFileAtomic is WriteBytesToFile writing atomically, see AtomicWriter.
+func WriteBytesToFileAtomic(file string, content []byte) error {
+	return WriteFileAtomic(file, content, 0o644)
+}
+
+// WriteCSVAtomic is WriteCSV writing atomically, see AtomicWriter. Appending copies the
+// existing content first.
+func WriteCSVAtomic(file string, records [][]string, appendToTail bool, delimiter ...rune) error {
+	return writeAtomic(file, 0o644, appendToTail, func(w io.Writer) error {
+		return writeCSVRecords(w, records, delimiter...)
+	})
+}
+
+// writeAtomic writes path atomically with write, after its current content if
+// appendToTail is set. An existing file keeps its permissions when appending.
+func writeAtomic(path string, perm os.FileMode, appendToTail bool, write func(w io.Writer) error) error {
+	var existing *os.File
+	if appendToTail {
+		f, err := os.Open(path) //nolint:gosec
+		switch {
+		case err == nil:
+			defer f.Close()
+			existing = f
+			if info, err := f.Stat(); err == nil {
+				perm = info.Mode().Perm()
+			}
+		case !errors.Is(err, os.ErrNotExist):
+			return err
+		}
+	}
+
+	w, err := NewAtomicWriter(path, perm)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if existing != nil {
+		if _, err := io.Copy(w, existing); err != nil {
+			return err
+		}
+	}
+	if err := write(w); err != nil {
+		return err
+	}
+	return w.Commit()
+}
+
+// SyncDir flushes the entries of dir to disk, making the creation, rename or removal of
+// a file in dir durable. Directories cannot be opened for syncing on Windows, where it
+// is skipped.
+func SyncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := os.Open(dir) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
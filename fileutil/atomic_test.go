@@ -0,0 +1,160 @@
+package fileutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// dirEntries returns the names in dir.
+func dirEntries(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	return names
+}
+
+func TestAtomicWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	if err := os.WriteFile(path, []byte("old"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	w, err := NewAtomicWriter(path, 0o640)
+	if err != nil {
+		t.Fatalf("NewAtomicWriter() error = %v", err)
+	}
+	if _, err := w.WriteString("new "); err != nil {
+		t.Fatalf("WriteString() error = %v", err)
+	}
+	if _, err := w.Write([]byte("content")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	// Nothing is visible before Commit
+	if content, _ := os.ReadFile(path); string(content) != "old" {
+		t.Errorf("content before Commit = %q, want \"old\"", content)
+	}
+
+	if err := w.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "new content" {
+		t.Errorf("content after Commit = %q, want \"new content\"", content)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o640 {
+		t.Errorf("mode = %v, %v, want 0640", info.Mode().Perm(), err)
+	}
+	if names := dirEntries(t, dir); len(names) != 1 {
+		t.Errorf("Commit() left %v", names)
+	}
+
+	if _, err := w.Write([]byte("x")); !errors.Is(err, ErrAtomicWriterClosed) {
+		t.Errorf("Write() after Commit error = %v, want ErrAtomicWriterClosed", err)
+	}
+	if err := w.Commit(); !errors.Is(err, ErrAtomicWriterClosed) {
+		t.Errorf("Commit() twice error = %v, want ErrAtomicWriterClosed", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("Close() after Commit error = %v", err)
+	}
+}
+
+func TestAtomicWriter_Close(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	if err := os.WriteFile(path, []byte("old"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	w, err := NewAtomicWriter(path, 0o644)
+	if err != nil {
+		t.Fatalf("NewAtomicWriter() error = %v", err)
+	}
+	_, _ = w.WriteString("discarded")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if content, _ := os.ReadFile(path); string(content) != "old" {
+		t.Errorf("content after Close = %q, want \"old\"", content)
+	}
+	if names := dirEntries(t, dir); len(names) != 1 {
+		t.Errorf("Close() left %v", names)
+	}
+
+	if _, err := NewAtomicWriter(filepath.Join(dir, "missing", "file"), 0o644); err == nil {
+		t.Error("NewAtomicWriter() expected error for a missing directory")
+	}
+}
+
+func TestWriteFileAtomic_Symlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.txt")
+	link := filepath.Join(dir, "link.txt")
+	if err := os.WriteFile(target, []byte("old"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := os.Symlink("target.txt", link); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+
+	if err := WriteFileAtomic(link, []byte("new"), 0o600); err != nil {
+		t.Fatalf("WriteFileAtomic() error = %v", err)
+	}
+	if !IsLink(link) {
+		t.Error("WriteFileAtomic() replaced the symbolic link")
+	}
+	if content, _ := os.ReadFile(target); string(content) != "new" {
+		t.Errorf("target content = %q, want \"new\"", content)
+	}
+}
+
+func TestWriteAtomicVariants(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "log.txt")
+	if err := WriteStringToFileAtomic(path, "one\n", true); err != nil {
+		t.Fatalf("WriteStringToFileAtomic() error = %v", err)
+	}
+	if err := WriteStringToFileAtomic(path, "two\n", true); err != nil {
+		t.Fatalf("WriteStringToFileAtomic() error = %v", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "one\ntwo\n" {
+		t.Errorf("appended content = %q", content)
+	}
+	if err := WriteStringToFileAtomic(path, "three\n", false); err != nil {
+		t.Fatalf("WriteStringToFileAtomic() error = %v", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "three\n" {
+		t.Errorf("overwritten content = %q", content)
+	}
+
+	bin := filepath.Join(dir, "data.bin")
+	if err := WriteBytesToFileAtomic(bin, []byte{1, 2, 3}); err != nil {
+		t.Fatalf("WriteBytesToFileAtomic() error = %v", err)
+	}
+	if content, _ := os.ReadFile(bin); string(content) != "\x01\x02\x03" {
+		t.Errorf("bytes content = %q", content)
+	}
+
+	csvFile := filepath.Join(dir, "data.csv")
+	if err := WriteCSVAtomic(csvFile, [][]string{{"a", "b"}}, false); err != nil {
+		t.Fatalf("WriteCSVAtomic() error = %v", err)
+	}
+	if err := WriteCSVAtomic(csvFile, [][]string{{"c", "d"}}, true, ';'); err != nil {
+		t.Fatalf("WriteCSVAtomic() error = %v", err)
+	}
+	if content, _ := os.ReadFile(csvFile); string(content) != "a,b\nc;d\n" {
+		t.Errorf("csv content = %q", content)
+	}
+}
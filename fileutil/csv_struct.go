@@ -0,0 +1,665 @@
+package fileutil
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ztime "github.com/kydance/ziwi/time"
+)
+
+// CSVConverter converts the values of one type to and from csv fields
+type CSVConverter struct {
+	Parse  func(field string) (any, error) // returns a value assignable to the type
+	Format func(value any) (string, error) // receives a value of the type
+}
+
+// CSVOptions configures ReadCSVInto, IterCSV and WriteStructsToCSV.
+type CSVOptions struct {
+	// Delimiter is the field delimiter, ',' by default.
+	Delimiter rune
+	// Header lists the columns. When reading a file without header row it maps
+	// the columns to fields; when writing it selects and orders the columns.
+	// By default the header row of the file, or all the fields, are used.
+	Header []string
+	// NoHeader reports that the file has no header row. Columns are mapped by
+	// Header if set, otherwise in field order.
+	NoHeader bool
+	// Strict makes unknown columns, and fields without a column, an error.
+	Strict bool
+	// TimeFormat is the format of time.Time fields: a key of the ziwi time
+	// formats such as "yyyy-mm-dd hh:mm:ss", or a Go layout. RFC 3339 by default.
+	TimeFormat string
+	// Location is the location of times without zone information, UTC by default.
+	Location *time.Location
+	// Converters overrides the conversion of the given types.
+	Converters map[reflect.Type]CSVConverter
+	// Append appends the rows to an existing file, writing the header row only
+	// if the file is empty.
+	Append bool
+}
+
+// CSVRowError is an error in a single csv record
+type CSVRowError struct {
+	Line   int    // line the record starts on
+	Column string // column of the invalid field, empty if the error is not about a single field
+	Err    error
+}
+
+func (e *CSVRowError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("csv line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("csv line %d, column %q: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *CSVRowError) Unwrap() error { return e.Err }
+
+// ReadCSVInto reads the csv file into a slice of T, which must be a struct or
+// a pointer to a struct. Columns are mapped to fields by their `csv:"name"`
+// tag, or their name if untagged; fields tagged `csv:"-"` are ignored. The
+// fields of an untagged embedded struct are columns too, as with encoding/json.
+//
+// Every invalid record is reported as a *CSVRowError, the returned error
+// joins them; the valid records are returned either way.
+func ReadCSVInto[T any](file string, opts CSVOptions) ([]T, error) {
+	var (
+		rows []T
+		errs []error
+	)
+	for row, err := range IterCSV[T](file, opts) {
+		var rowErr *CSVRowError
+		switch {
+		case err == nil:
+			rows = append(rows, row)
+		case errors.As(err, &rowErr):
+			errs = append(errs, err)
+		default:
+			return rows, errors.Join(append(errs, err)...)
+		}
+	}
+	return rows, errors.Join(errs...)
+}
+
+// IterCSV streams the records of the csv file as values of T, see ReadCSVInto.
+// An invalid record yields a *CSVRowError and iteration may go on; any other
+// error ends the iteration.
+func IterCSV[T any](file string, opts CSVOptions) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		f, err := os.Open(file) //nolint:gosec
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer f.Close()
+
+		codec, err := newCSVCodec[T](opts)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+
+		reader := csv.NewReader(f)
+		reader.Comma = codec.delimiter
+		reader.FieldsPerRecord = -1 // reported per row instead
+
+		columns, err := codec.readHeader(reader)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+
+		for {
+			record, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				// The record is malformed, the reader can go on with the next one
+				err = &CSVRowError{Line: parseErr.StartLine, Err: parseErr.Err}
+				if !yield(zero, err) {
+					return
+				}
+				continue
+			}
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+
+			line, _ := reader.FieldPos(0)
+			row, err := codec.decode(record, columns, line)
+			if !yield(row, err) {
+				return
+			}
+		}
+	}
+}
+
+// WriteStructsToCSV writes rows to the csv file with a header row, see
+// ReadCSVInto for the column mapping. Fields tagged `csv:"name,omitempty"`
+// are written empty when they hold their zero value.
+func WriteStructsToCSV[T any](file string, rows []T, opts CSVOptions) error {
+	codec, err := newCSVCodec[T](opts)
+	if err != nil {
+		return err
+	}
+
+	columns := codec.fields
+	if len(opts.Header) > 0 {
+		if columns, err = codec.columns(opts.Header); err != nil {
+			return err
+		}
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if opts.Append {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(file, flag, 0o644) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	writer := csv.NewWriter(f)
+	writer.Comma = codec.delimiter
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !opts.NoHeader && info.Size() == 0 {
+		header := make([]string, len(columns))
+		for i, field := range columns {
+			header[i] = field.name
+		}
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+	}
+
+	record := make([]string, len(columns))
+	for i, row := range rows {
+		v := reflect.ValueOf(row)
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return fmt.Errorf("row %d: nil pointer", i)
+			}
+			v = v.Elem()
+		}
+
+		for j, field := range columns {
+			fv, fieldErr := v.FieldByIndexErr(field.index)
+			if fieldErr != nil {
+				record[j] = "" // the field is in a nil embedded struct
+				continue
+			}
+			if record[j], err = codec.encodeField(fv, field.omitEmpty); err != nil {
+				return fmt.Errorf("row %d, column %q: %w", i, field.name, err)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// csvField is a struct field mapped to a csv column
+type csvField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	tagged    bool // named by its tag
+}
+
+// csvFieldCache caches the fields of the struct types, by reflect.Type
+var csvFieldCache sync.Map
+
+// csvFields returns the csv columns of the struct type t.
+//
+// As with encoding/json, the fields of an untagged anonymous struct field are promoted
+// to columns of t. When several fields have the same name, the least nested one wins,
+// then the one named by its tag; if that leaves a tie, none of them is a column.
+func csvFields(t reflect.Type) []csvField {
+	if cached, ok := csvFieldCache.Load(t); ok {
+		fields, _ := cached.([]csvField)
+		return fields
+	}
+
+	all := collectCSVFields(t, nil, map[reflect.Type]bool{})
+	fields := make([]csvField, 0, len(all))
+	for _, f := range all {
+		if dominantCSVField(all, f) {
+			fields = append(fields, f)
+		}
+	}
+
+	csvFieldCache.Store(t, fields)
+	return fields
+}
+
+// collectCSVFields returns the candidate columns of the struct type t, reached
+// through the field index prefix. Types in visiting are being walked already.
+func collectCSVFields(t reflect.Type, prefix []int, visiting map[reflect.Type]bool) []csvField {
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	var fields []csvField
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		name, opts, _ := strings.Cut(sf.Tag.Get("csv"), ",")
+		if name == "-" {
+			continue
+		}
+		index := append(append([]int(nil), prefix...), i)
+
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if promotesCSVFields(ft) {
+				// Fields of an unexported embedded pointer cannot be set
+				if (sf.IsExported() || sf.Type.Kind() != reflect.Pointer) && !visiting[ft] {
+					fields = append(fields, collectCSVFields(ft, index, visiting)...)
+				}
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		tagged := name != ""
+		if !tagged {
+			name = sf.Name
+		}
+		fields = append(fields, csvField{name: name, index: index, omitEmpty: opts == "omitempty", tagged: tagged})
+	}
+	return fields
+}
+
+// promotesCSVFields reports whether the fields of an embedded t become columns, rather
+// than t being a single column.
+func promotesCSVFields(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+	pt := reflect.PointerTo(t)
+	return !pt.Implements(textUnmarshalerType) && !pt.Implements(scannerType)
+}
+
+// dominantCSVField reports whether f is the column for its name among all.
+func dominantCSVField(all []csvField, f csvField) bool {
+	for _, other := range all {
+		if other.name != f.name || reflect.DeepEqual(other.index, f.index) {
+			continue
+		}
+		switch {
+		case len(other.index) < len(f.index):
+			return false
+		case len(other.index) > len(f.index):
+		case other.tagged == f.tagged, other.tagged:
+			return false
+		}
+	}
+	return true
+}
+
+// csvCodec converts between csv records and values of T
+type csvCodec[T any] struct {
+	fields     []csvField
+	delimiter  rune
+	noHeader   bool
+	header     []string
+	strict     bool
+	timeLayout string
+	location   *time.Location
+	converters map[reflect.Type]CSVConverter
+	isPointer  bool         // T is a pointer to the struct
+	structType reflect.Type // the struct type
+}
+
+func newCSVCodec[T any](opts CSVOptions) (*csvCodec[T], error) {
+	t := reflect.TypeFor[T]()
+	c := &csvCodec[T]{
+		delimiter:  ',',
+		noHeader:   opts.NoHeader,
+		header:     opts.Header,
+		strict:     opts.Strict,
+		timeLayout: time.RFC3339,
+		location:   time.UTC,
+		converters: opts.Converters,
+		structType: t,
+	}
+	if t.Kind() == reflect.Pointer {
+		c.isPointer, c.structType = true, t.Elem()
+	}
+	if c.structType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unsupported type %v: must be a struct or a pointer to a struct", t)
+	}
+
+	if opts.Delimiter != 0 {
+		c.delimiter = opts.Delimiter
+	}
+	if opts.TimeFormat != "" {
+		c.timeLayout = opts.TimeFormat
+		if layout, ok := ztime.TimeFormat[strings.ToLower(opts.TimeFormat)]; ok {
+			c.timeLayout = layout
+		}
+	}
+	if opts.Location != nil {
+		c.location = opts.Location
+	}
+
+	c.fields = csvFields(c.structType)
+	return c, nil
+}
+
+// readHeader returns the field of every column, nil for ignored columns.
+func (c *csvCodec[T]) readHeader(reader *csv.Reader) ([]*csvField, error) {
+	header := c.header
+	if !c.noHeader {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil, nil // empty file
+		}
+		if err != nil {
+			return nil, err
+		}
+		header = append([]string(nil), record...)
+		if len(header) > 0 {
+			header[0] = strings.TrimPrefix(header[0], "\ufeff") // UTF-8 BOM
+		}
+	}
+
+	if len(header) == 0 {
+		// No header at all, the columns follow the fields
+		columns := make([]*csvField, len(c.fields))
+		for i := range c.fields {
+			columns[i] = &c.fields[i]
+		}
+		return columns, nil
+	}
+
+	columns := make([]*csvField, len(header))
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		for j := range c.fields {
+			if strings.EqualFold(c.fields[j].name, name) {
+				columns[i] = &c.fields[j]
+				seen[c.fields[j].name] = true
+				break
+			}
+		}
+		if columns[i] == nil && c.strict {
+			return nil, fmt.Errorf("unknown csv column %q", name)
+		}
+	}
+
+	if c.strict {
+		for _, field := range c.fields {
+			if !seen[field.name] {
+				return nil, fmt.Errorf("missing csv column %q", field.name)
+			}
+		}
+	}
+	return columns, nil
+}
+
+// columns returns the fields of the named columns, in order.
+func (c *csvCodec[T]) columns(names []string) ([]csvField, error) {
+	columns := make([]csvField, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, field := range c.fields {
+			if strings.EqualFold(field.name, name) {
+				columns = append(columns, field)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown csv column %q", name)
+		}
+	}
+	return columns, nil
+}
+
+// decode converts a record starting on line into a T.
+func (c *csvCodec[T]) decode(record []string, columns []*csvField, line int) (T, error) {
+	var zero T
+	if c.strict && len(record) != len(columns) {
+		return zero, &CSVRowError{
+			Line: line,
+			Err:  fmt.Errorf("expected %d fields, got %d", len(columns), len(record)),
+		}
+	}
+
+	v := reflect.New(c.structType).Elem()
+	for i, value := range record {
+		if i >= len(columns) || columns[i] == nil {
+			continue // ignored column
+		}
+		if err := c.decodeField(fieldByIndexAlloc(v, columns[i].index), value); err != nil {
+			return zero, &CSVRowError{Line: line, Column: columns[i].name, Err: err}
+		}
+	}
+
+	if c.isPointer {
+		row, _ := v.Addr().Interface().(T)
+		return row, nil
+	}
+	row, _ := v.Interface().(T)
+	return row, nil
+}
+
+// fieldByIndexAlloc is v.FieldByIndex, allocating the nil embedded structs on the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+var (
+	timeType     = reflect.TypeFor[time.Time]()
+	durationType = reflect.TypeFor[time.Duration]()
+
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	scannerType         = reflect.TypeFor[sql.Scanner]()
+)
+
+// decodeField parses s into the settable value v.
+func (c *csvCodec[T]) decodeField(v reflect.Value, s string) error {
+	if conv, ok := c.converters[v.Type()]; ok && conv.Parse != nil {
+		parsed, err := conv.Parse(s)
+		if err != nil {
+			return err
+		}
+		pv := reflect.ValueOf(parsed)
+		if !pv.IsValid() || !pv.Type().AssignableTo(v.Type()) {
+			return fmt.Errorf("converter returned %T, want %v", parsed, v.Type())
+		}
+		v.Set(pv)
+		return nil
+	}
+
+	// Empty fields are nil pointers and null values
+	if v.Kind() == reflect.Pointer {
+		if s == "" {
+			v.SetZero()
+			return nil
+		}
+		elem := reflect.New(v.Type().Elem())
+		if err := c.decodeField(elem.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if scanner, ok := v.Addr().Interface().(sql.Scanner); ok {
+		if s == "" {
+			return scanner.Scan(nil)
+		}
+		return scanner.Scan(s)
+	}
+
+	switch v.Type() {
+	case timeType:
+		if s == "" {
+			v.SetZero()
+			return nil
+		}
+		t, err := time.ParseInLocation(c.timeLayout, s, c.location)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		if s == "" {
+			v.SetZero()
+			return nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	if unmarshaler, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(s))
+	}
+
+	return decodeBasic(v, s)
+}
+
+// decodeBasic parses s into a value of a basic kind. An empty field is the zero value.
+func decodeBasic(v reflect.Value, s string) error {
+	if v.Kind() == reflect.String {
+		v.SetString(s)
+		return nil
+	}
+	if s = strings.TrimSpace(s); s == "" {
+		v.SetZero()
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %v", v.Type())
+	}
+	return nil
+}
+
+// encodeField formats the value v as a csv field.
+func (c *csvCodec[T]) encodeField(v reflect.Value, omitEmpty bool) (string, error) {
+	if omitEmpty && v.IsZero() {
+		return "", nil
+	}
+	if conv, ok := c.converters[v.Type()]; ok && conv.Format != nil {
+		return conv.Format(v.Interface())
+	}
+
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", nil
+		}
+		return c.encodeField(v.Elem(), false)
+	}
+	if valuer, ok := v.Interface().(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil || value == nil {
+			return "", err
+		}
+		if t, ok := value.(time.Time); ok {
+			return t.Format(c.timeLayout), nil
+		}
+		return fmt.Sprint(value), nil
+	}
+
+	switch v.Type() {
+	case timeType:
+		t, _ := v.Interface().(time.Time)
+		if t.IsZero() {
+			return "", nil
+		}
+		return t.Format(c.timeLayout), nil
+	case durationType:
+		return time.Duration(v.Int()).String(), nil
+	}
+
+	if marshaler, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	default:
+		return "", fmt.Errorf("unsupported field type %v", v.Type())
+	}
+}
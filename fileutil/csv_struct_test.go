@@ -0,0 +1,310 @@
+package fileutil
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type csvPerson struct {
+	Name     string         `csv:"name"`
+	Age      int            `csv:"age"`
+	Score    float64        `csv:"score"`
+	Active   bool           `csv:"active"`
+	Born     time.Time      `csv:"born"`
+	Nick     *string        `csv:"nick"`
+	Email    sql.NullString `csv:"email"`
+	Timeout  time.Duration  `csv:"timeout,omitempty"`
+	Internal string         `csv:"-"`
+	Note     string
+}
+
+func writeCSVFile(t *testing.T, content string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return file
+}
+
+func TestReadCSVInto(t *testing.T) {
+	file := writeCSVFile(t, "\ufeffname,Age,score,active,born,nick,email,timeout,Note,extra\n"+
+		"alice,30,1.5,true,2024-01-02 03:04:05,al,a@x.io,1m,hi,ignored\n"+
+		"bob,,0,false,,,,,,\n")
+
+	rows, err := ReadCSVInto[csvPerson](file, CSVOptions{TimeFormat: "yyyy-mm-dd hh:mm:ss"})
+	if err != nil {
+		t.Fatalf("ReadCSVInto() error = %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("ReadCSVInto() got %d rows, want 2", len(rows))
+	}
+
+	alice := rows[0]
+	if alice.Name != "alice" || alice.Age != 30 || alice.Score != 1.5 || !alice.Active || alice.Note != "hi" {
+		t.Errorf("ReadCSVInto() row 0 = %+v", alice)
+	}
+	if want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC); !alice.Born.Equal(want) {
+		t.Errorf("ReadCSVInto() born = %v, want %v", alice.Born, want)
+	}
+	if alice.Nick == nil || *alice.Nick != "al" {
+		t.Errorf("ReadCSVInto() nick = %v, want al", alice.Nick)
+	}
+	if !alice.Email.Valid || alice.Email.String != "a@x.io" {
+		t.Errorf("ReadCSVInto() email = %+v", alice.Email)
+	}
+	if alice.Timeout != time.Minute {
+		t.Errorf("ReadCSVInto() timeout = %v, want 1m", alice.Timeout)
+	}
+
+	bob := rows[1]
+	if bob.Nick != nil || bob.Email.Valid || bob.Age != 0 || !bob.Born.IsZero() {
+		t.Errorf("ReadCSVInto() row 1 = %+v, want empty fields as nil and zero values", bob)
+	}
+}
+
+func TestReadCSVInto_RowErrors(t *testing.T) {
+	file := writeCSVFile(t, "name,age\nalice,30\nbob,old\ncarol,\"bad\n\"x\ndave,40\n")
+
+	rows, err := ReadCSVInto[*csvPerson](file, CSVOptions{})
+	if err == nil {
+		t.Fatal("ReadCSVInto() expected row errors")
+	}
+	if len(rows) != 2 || rows[0].Name != "alice" || rows[1].Name != "dave" {
+		t.Errorf("ReadCSVInto() should return the valid rows, got %d", len(rows))
+	}
+
+	var rowErr *CSVRowError
+	if !errors.As(err, &rowErr) {
+		t.Fatalf("ReadCSVInto() error = %v, want a *CSVRowError", err)
+	}
+	if rowErr.Line != 3 || rowErr.Column != "age" {
+		t.Errorf("first row error = line %d column %q, want line 3 column \"age\"", rowErr.Line, rowErr.Column)
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("ReadCSVInto() error should wrap the conversion error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "csv line 4") {
+		t.Errorf("ReadCSVInto() error should report the malformed record on line 4, got %v", err)
+	}
+}
+
+func TestReadCSVInto_Options(t *testing.T) {
+	type point struct {
+		X int `csv:"x"`
+		Y int `csv:"y"`
+	}
+
+	t.Run("no header", func(t *testing.T) {
+		file := writeCSVFile(t, "1;2\n3;4\n")
+		rows, err := ReadCSVInto[point](file, CSVOptions{Delimiter: ';', NoHeader: true})
+		if err != nil {
+			t.Fatalf("ReadCSVInto() error = %v", err)
+		}
+		if want := []point{{1, 2}, {3, 4}}; !reflect.DeepEqual(rows, want) {
+			t.Errorf("ReadCSVInto() = %v, want %v", rows, want)
+		}
+	})
+
+	t.Run("header option", func(t *testing.T) {
+		file := writeCSVFile(t, "1,2\n")
+		rows, err := ReadCSVInto[point](file, CSVOptions{NoHeader: true, Header: []string{"y", "x"}})
+		if err != nil {
+			t.Fatalf("ReadCSVInto() error = %v", err)
+		}
+		if want := []point{{2, 1}}; !reflect.DeepEqual(rows, want) {
+			t.Errorf("ReadCSVInto() = %v, want %v", rows, want)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		for _, content := range []string{"x,y,z\n1,2,3\n", "x\n1\n"} {
+			file := writeCSVFile(t, content)
+			if _, err := ReadCSVInto[point](file, CSVOptions{Strict: true}); err == nil {
+				t.Errorf("ReadCSVInto(%q) expected a strict header error", content)
+			}
+		}
+	})
+
+	t.Run("converter", func(t *testing.T) {
+		file := writeCSVFile(t, "x,y\n0x10,2\n")
+		converters := map[reflect.Type]CSVConverter{
+			reflect.TypeFor[int](): {Parse: func(s string) (any, error) {
+				n, err := strconv.ParseInt(s, 0, 64)
+				return int(n), err
+			}},
+		}
+		rows, err := ReadCSVInto[point](file, CSVOptions{Converters: converters})
+		if err != nil {
+			t.Fatalf("ReadCSVInto() error = %v", err)
+		}
+		if want := []point{{16, 2}}; !reflect.DeepEqual(rows, want) {
+			t.Errorf("ReadCSVInto() = %v, want %v", rows, want)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		if _, err := ReadCSVInto[point]("non_existent.csv", CSVOptions{}); err == nil {
+			t.Error("ReadCSVInto() expected error for a missing file")
+		}
+		file := writeCSVFile(t, "x\n1\n")
+		if _, err := ReadCSVInto[int](file, CSVOptions{}); err == nil {
+			t.Error("ReadCSVInto() expected error for a non-struct type")
+		}
+	})
+}
+
+func TestIterCSV(t *testing.T) {
+	file := writeCSVFile(t, "name\na\nb\nc\n")
+
+	var got []string
+	for row, err := range IterCSV[csvPerson](file, CSVOptions{}) {
+		if err != nil {
+			t.Fatalf("IterCSV() error = %v", err)
+		}
+		got = append(got, row.Name)
+		if len(got) == 2 {
+			break
+		}
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("IterCSV() = %v, want %v", got, want)
+	}
+}
+
+func TestWriteStructsToCSV(t *testing.T) {
+	nick := "al"
+	rows := []csvPerson{
+		{
+			Name: "alice, \"the first\"", Age: 30, Score: 1.5, Active: true,
+			Born: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Nick: &nick,
+			Email: sql.NullString{String: "a@x.io", Valid: true}, Timeout: time.Minute, Internal: "secret",
+		},
+		{Name: "bob"},
+	}
+
+	file := filepath.Join(t.TempDir(), "out.csv")
+	opts := CSVOptions{TimeFormat: "yyyy-mm-dd hh:mm:ss"}
+	if err := WriteStructsToCSV(file, rows, opts); err != nil {
+		t.Fatalf("WriteStructsToCSV() error = %v", err)
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	want := "name,age,score,active,born,nick,email,timeout,Note\n" +
+		"\"alice, \"\"the first\"\"\",30,1.5,true,2024-01-02 03:04:05,al,a@x.io,1m0s,\n" +
+		"bob,0,0,false,,,,,\n"
+	if string(content) != want {
+		t.Errorf("WriteStructsToCSV() wrote\n%s\nwant\n%s", content, want)
+	}
+
+	// Round trip, the ignored field aside
+	got, err := ReadCSVInto[csvPerson](file, opts)
+	if err != nil {
+		t.Fatalf("ReadCSVInto() error = %v", err)
+	}
+	rows[0].Internal = ""
+	if !reflect.DeepEqual(got, rows) {
+		t.Errorf("round trip = %+v, want %+v", got, rows)
+	}
+}
+
+func TestWriteStructsToCSV_Options(t *testing.T) {
+	type point struct {
+		X int `csv:"x"`
+		Y int `csv:"y"`
+	}
+
+	file := filepath.Join(t.TempDir(), "out.csv")
+	opts := CSVOptions{Header: []string{"y", "x"}, Append: true}
+	if err := WriteStructsToCSV(file, []*point{{1, 2}}, opts); err != nil {
+		t.Fatalf("WriteStructsToCSV() error = %v", err)
+	}
+	if err := WriteStructsToCSV(file, []*point{{3, 4}}, opts); err != nil {
+		t.Fatalf("WriteStructsToCSV() append error = %v", err)
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if want := "y,x\n2,1\n4,3\n"; string(content) != want {
+		t.Errorf("WriteStructsToCSV() wrote %q, want %q", content, want)
+	}
+
+	if err := WriteStructsToCSV(file, []point{{}}, CSVOptions{Header: []string{"z"}}); err == nil {
+		t.Error("WriteStructsToCSV() expected error for an unknown column")
+	}
+	if err := WriteStructsToCSV(file, []*point{nil}, CSVOptions{}); err == nil {
+		t.Error("WriteStructsToCSV() expected error for a nil row")
+	}
+}
+
+type csvAudit struct {
+	Created string `csv:"created"`
+	ID      int    `csv:"id"` // shadowed by csvRecord.ID
+}
+
+type csvOwner struct {
+	Owner string `csv:"owner"`
+}
+
+// CSVContact is exported: the fields of an unexported embedded pointer cannot be set
+type CSVContact struct {
+	Owner string `csv:"owner"`
+}
+
+type csvRecord struct {
+	ID int `csv:"id"`
+	csvAudit
+	*csvOwner `csv:"-"`
+	Author    *csvOwner `csv:"author"`
+	Meta      csvOwner  `csv:"meta"`
+	Extra     *struct {
+		Tag string `csv:"tag"`
+	}
+}
+
+func TestCSV_EmbeddedStructs(t *testing.T) {
+	type row struct {
+		csvRecord
+		*CSVContact
+	}
+
+	var names []string
+	for _, f := range csvFields(reflect.TypeFor[row]()) {
+		names = append(names, f.name)
+	}
+	if want := []string{"id", "created", "author", "meta", "Extra", "owner"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("csvFields() = %v, want %v", names, want)
+	}
+
+	file := writeCSVFile(t, "id,created,owner\n1,today,carol\n")
+	got, err := ReadCSVInto[row](file, CSVOptions{})
+	if err != nil {
+		t.Fatalf("ReadCSVInto() error = %v", err)
+	}
+	if len(got) != 1 || got[0].ID != 1 || got[0].Created != "today" || got[0].CSVContact == nil ||
+		got[0].Owner != "carol" {
+		t.Fatalf("ReadCSVInto() = %+v", got)
+	}
+
+	out := filepath.Join(t.TempDir(), "out.csv")
+	opts := CSVOptions{Header: []string{"id", "created", "owner"}}
+	if err := WriteStructsToCSV(out, []row{got[0], {csvRecord: csvRecord{ID: 2}}}, opts); err != nil {
+		t.Fatalf("WriteStructsToCSV() error = %v", err)
+	}
+	if content, _ := os.ReadFile(out); string(content) != "id,created,owner\n1,today,carol\n2,,\n" {
+		t.Errorf("WriteStructsToCSV() wrote %q", content)
+	}
+}
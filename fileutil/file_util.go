@@ -11,9 +11,9 @@
 package fileutil
 
 import (
-	"archive/zip"
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/sha1" //nolint
 	"crypto/sha256"
 	"crypto/sha512"
@@ -128,20 +128,9 @@ func CreateDir(dir string) error {
 
 // CopyFile copies a file from src to dst. Support relative / absolute path.
 func CopyFile(dst, src string) error {
-	sf, err := os.Open(src) //nolint:gosec
-	if err != nil {
-		return err
-	}
-	defer sf.Close()
-
-	df, err := os.Create(dst) //nolint:gosec
-	if err != nil {
-		return err
-	}
-	defer df.Close()
-
-	_, err = io.Copy(df, sf)
-	return err
+	dstFS, dstName := osPath(dst)
+	srcFS, srcName := osPath(src)
+	return CopyFileFS(dstFS, dstName, srcFS, srcName)
 }
 
 // RemoveFile removes a specifice file.
@@ -164,42 +153,9 @@ func ClearFile(file string) error {
 // CopyDir copies a directory including all subdirectories and files from src to dst recursively.
 // Support relative / absolute path. If dst does not exist, it will return error.
 func CopyDir(dst, src string) error {
-	srcInfo, err := os.Stat(src)
-	if err != nil {
-		return fmt.Errorf("failed to get source directory info: %w", err)
-	}
-	if !srcInfo.IsDir() {
-		return fmt.Errorf("source is not a directory: %s", src)
-	}
-
-	err = os.MkdirAll(dst, 0o775) //nolint:gosec
-	if err != nil {
-		return fmt.Errorf("failed to create destination directory: %w", err)
-	}
-
-	vEntry, err := os.ReadDir(src)
-	if err != nil {
-		return fmt.Errorf("failed to read source directory: %w", err)
-	}
-
-	for _, entry := range vEntry {
-		_src := filepath.Join(src, entry.Name())
-		_dst := filepath.Join(dst, entry.Name())
-
-		if entry.IsDir() {
-			err := CopyDir(_dst, _src)
-			if err != nil {
-				return err
-			}
-		} else {
-			err := CopyFile(_dst, _src)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
+	dstFS, dstName := osPath(dst)
+	srcFS, srcName := osPath(src)
+	return CopyDirFS(dstFS, dstName, srcFS, srcName)
 }
 
 // IsDir checks if a path is a directory.
@@ -252,24 +208,7 @@ func FilesCurDir(dir string) ([]string, error) {
 		return nil, nil
 	}
 
-	vEntry, err := os.ReadDir(dir)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(vEntry) == 0 {
-		return nil, nil
-	}
-
-	// vector of filenames
-	vfn := make([]string, 0)
-	for _, entry := range vEntry {
-		if IsDir(filepath.Join(dir, entry.Name())) {
-			continue
-		}
-		vfn = append(vfn, entry.Name())
-	}
-	return vfn, nil
+	return FilesCurDirFS(osPath(dir))
 }
 
 // IsZipFile checks if a file is a zip file (PK\x03\x04).
@@ -297,117 +236,10 @@ func Zip(dst, src string) error {
 	return zipFile(dst, src)
 }
 
-// UnZip decompresses a zip file to dst.
-// `src` usually ends with ".zip
+// UnZip decompresses a zip file to dst, keeping the permissions of its entries.
+// `src` usually ends with ".zip". See UnZipWithOptions to limit the extracted size.
 func UnZip(dst, src string) error {
-	zipReader, err := zip.OpenReader(src)
-	if err != nil {
-		return err
-	}
-	defer func() { _ = zipReader.Close() }()
-
-	for _, f := range zipReader.File {
-		path, err := safeFilepathJoin(dst, f.Name)
-		if err != nil {
-			return err
-		}
-
-		if f.FileInfo().IsDir() {
-			err = os.MkdirAll(path, os.ModePerm) //nolint:gosec
-			if err != nil {
-				return err
-			}
-		} else {
-			err = os.MkdirAll(filepath.Dir(path), os.ModePerm) //nolint:gosec
-			if err != nil {
-				return err
-			}
-
-			inFile, err := f.Open()
-			if err != nil {
-				return err
-			}
-			defer func() { _ = inFile.Close() }()
-
-			outFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode()) //nolint:gosec
-			if err != nil {
-				return err
-			}
-			defer outFile.Close()
-
-			// FIXME G110: Potential DoS vulnerability via decompression bomb (gosec)
-			// #nosec
-			_, err = io.Copy(outFile, inFile)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
-}
-
-// ZipAppendEntry appends a file or directory to a zip file.
-func ZipAppendEntry(dst, src string) error {
-	tempFile, err := os.CreateTemp("", "temp.zip")
-	if err != nil {
-		return err
-	}
-	defer os.Remove(tempFile.Name())
-
-	zipReader, err := zip.OpenReader(src)
-	if err != nil {
-		return err
-	}
-
-	archive := zip.NewWriter(tempFile)
-
-	for _, item := range zipReader.File {
-		itemReader, err := item.Open()
-		if err != nil {
-			return err
-		}
-
-		header, err := zip.FileInfoHeader(item.FileInfo())
-		if err != nil {
-			return err
-		}
-
-		header.Name = item.Name
-		targetItem, err := archive.CreateHeader(header)
-		if err != nil {
-			return err
-		}
-
-		// FIXME G110: Potential DoS vulnerability via decompression bomb (gosec)
-		// #nosec
-		_, err = io.Copy(targetItem, itemReader)
-		if err != nil {
-			return err
-		}
-	}
-
-	err = addFileToArchive(archive, src)
-	if err != nil {
-		return err
-	}
-
-	err = zipReader.Close()
-	if err != nil {
-		return err
-	}
-
-	err = archive.Close()
-	if err != nil {
-		return err
-	}
-
-	err = tempFile.Close()
-	if err != nil {
-		return err
-	}
-
-	return CopyFile(dst, tempFile.Name())
+	return UnZipWithOptions(dst, src, UnZipOptions{PreserveMode: true})
 }
 
 // IsLink checks if the specified path is symbolic link or not.
@@ -487,24 +319,7 @@ func FileSize(file string) (int64, error) {
 
 // DirSize walks the folder recusively and returns folder size in bytes.
 func DirSize(dir string) (int64, error) {
-	var size int64
-
-	err := filepath.WalkDir(dir, func(_ string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if !d.IsDir() {
-			info, err := d.Info()
-			if err != nil {
-				return err
-			}
-
-			size += info.Size()
-		}
-		return err
-	})
-	return size, err
+	return DirSizeFS(osPath(dir))
 }
 
 // MTime return file modified time (Uinx timestamp).
@@ -565,7 +380,12 @@ func ReadCSV(file string, delimiter ...rune) ([][]string, error) {
 	}
 	defer f.Close()
 
-	reader := csv.NewReader(f)
+	return readCSVRecords(f, delimiter...)
+}
+
+// readCSVRecords reads all the records of r, see ReadCSV.
+func readCSVRecords(r io.Reader, delimiter ...rune) ([][]string, error) {
+	reader := csv.NewReader(r)
 	if len(delimiter) > 0 {
 		reader.Comma = delimiter[0]
 	}
@@ -594,7 +414,12 @@ func WriteCSV(file string, records [][]string, appendToTail bool, delimiter ...r
 	}
 	defer f.Close()
 
-	writer := csv.NewWriter(f)
+	return writeCSVRecords(f, records, delimiter...)
+}
+
+// writeCSVRecords writes records to w, see WriteCSV.
+func writeCSVRecords(w io.Writer, records [][]string, delimiter ...rune) error {
+	writer := csv.NewWriter(w)
 	if len(delimiter) > 0 {
 		writer.Comma = delimiter[0]
 	} else {
@@ -717,118 +542,81 @@ func ReadFile(path string) (reader io.ReadCloser, closeFn func(), err error) {
 }
 
 // ChunkRead reads a block from the file at the specified offset and
-// returns all lines within block
+// returns all lines within block. A block that ends at EOF is not an error.
 func ChunkRead(file *os.File, offset int64, size int, bufPool *sync.Pool) ([]string, error) {
 	// Get buf from pool and adjust size
 	temp, ok := bufPool.Get().(*[]byte)
 	if !ok {
 		return nil, errors.New("failed to get buffer from pool")
 	}
+	defer bufPool.Put(temp) // put buf back into pool once the lines are copied out
+
+	if cap(*temp) < size {
+		*temp = make([]byte, size)
+	}
 	buf := (*temp)[:size]
 
-	// Read data from offset position
+	// Read data from offset position, a short read at the end of the file is expected
 	n, err := file.ReadAt(buf, offset)
-	if err != nil && err == io.EOF {
+	if err != nil && !errors.Is(err, io.EOF) {
 		return nil, err
 	}
-	// Adjust to match real data
-	buf = buf[:n]
-
-	var (
-		lineBeg int // The begin of line
-		lines   []string
-	)
-	for idx, bVal := range buf {
-		if bVal == '\n' {
-			// Not include `\n`
-			line := string(buf[lineBeg:idx])
-			lines = append(lines, line)
-			// Set the begin of next line
-			lineBeg = idx + 1
-		}
-	}
 
-	// Handle the last lines of block
-	if lineBeg < len(buf) {
-		line := string(buf[lineBeg:])
-		lines = append(lines, line)
-	}
+	return splitLines(buf[:n]), nil
+}
 
-	// After reading data, put buf into pool
-	bufPool.Put(&buf)
-	return lines, nil
+// Chunk is a block of whole lines read by ParallelChunkRead
+type Chunk struct {
+	Seq    int      // index of the chunk in the file, increasing with Offset
+	Offset int64    // byte offset of the first line in the file
+	Lines  []string // lines without their trailing newline
 }
 
 // ParallelChunkRead reads a file in parallel chunks and sends each chunk
-// as a slice of strings to the provided channel. It uses goroutines to
-// read multiple chunks simultaneously, controlled by the maxGoroutine parameter.
-// If chunkSizeMB is 0, it defaults to 100MB.
-func ParallelChunkRead(file string, chLines chan<- []string, chunkSizeMB, maxGoroutine int) error {
+// to chChunks, which is closed when reading is over.
+//
+// Chunks are aligned to line boundaries, so no line is ever split between
+// two chunks. A line longer than a chunk is read whole by the chunk it starts
+// in; chunks in which no line starts are not sent, so Seq may skip numbers.
+// If ordered is true chunks are sent in file order, otherwise as soon as they
+// are read.
+//
+// It uses up to maxGoroutine goroutines, one per CPU if 0. If chunkSizeMB is 0,
+// it defaults to 100MB. The first read error, or ctx.Err() once ctx is done,
+// stops reading and is returned.
+func ParallelChunkRead(ctx context.Context, file string, chChunks chan<- Chunk,
+	chunkSizeMB, maxGoroutine int, ordered bool,
+) error {
+	defer close(chChunks)
+
 	// Default chunk size to 100MB if not specified
-	if chunkSizeMB == 0 {
+	if chunkSizeMB <= 0 {
 		chunkSizeMB = 100
 	}
-	// Calculate the chunk size in bytes
-	chunkSize := chunkSizeMB * 1024 * 1024
-
-	// Buffer pool for reusing byte slices to reduce memory allocation
-	bufPool := sync.Pool{
-		New: func() any {
-			buffer := make([]byte, 0, chunkSize)
-			return &buffer
-		},
-	}
-
 	// Default to the number of CPUs if maxGoroutine is not specified
-	if maxGoroutine == 0 {
+	if maxGoroutine <= 0 {
 		maxGoroutine = runtime.NumCPU()
 	}
 
-	// Open the file and handle errors
 	f, err := os.Open(file) //nolint:gosec
 	if err != nil {
 		return err
 	}
-	defer f.Close() // Ensure the file is closed after reading
+	defer f.Close()
 
-	// Get file info and handle errors
 	info, err := f.Stat()
 	if err != nil {
 		return err
 	}
 
-	// WaitGroup to wait for all goroutines to finish
-	wg := sync.WaitGroup{}
-	// Channel to manage chunk offsets for goroutines
-	chChunkOffset := make(chan int64, maxGoroutine)
-
-	// Allocate tasks by sending chunk offsets to the channel
-	go func() {
-		for i := int64(0); i < info.Size(); i += (int64(chunkSize)) {
-			chChunkOffset <- i
-		}
-		close(chChunkOffset) // Close the channel when all offsets are sent
-	}()
-
-	// Start work goroutines
-	for i := 0; i < maxGoroutine; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done() // Signal when the goroutine is done
-			for chunkOffset := range chChunkOffset {
-				// Read a chunk from the file
-				chunk, err := ChunkRead(f, chunkOffset, chunkSize, &bufPool)
-				if err != nil {
-					// Send the chunk to the channel even if there's an error
-					chLines <- chunk
-				}
-			}
-		}()
+	r := &chunkReader{
+		file:      f,
+		size:      info.Size(),
+		chunkSize: int64(chunkSizeMB) * 1024 * 1024,
+		bufPool: sync.Pool{New: func() any {
+			buf := make([]byte, 0, chunkSizeMB*1024*1024)
+			return &buf
+		}},
 	}
-
-	// Wait for all goroutines to finish
-	wg.Wait()
-	close(chLines) // Close the lines channel after all chunks are sent
-
-	return nil
+	return r.run(ctx, chChunks, maxGoroutine, ordered)
 }
@@ -2,11 +2,15 @@ package fileutil
 
 import (
 	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 func addFileToArchive(archive *zip.Writer, src string) error {
@@ -123,6 +127,53 @@ func safeFilepathJoin(path1, path2 string) (string, error) {
 	return filepath.Join(path1, filepath.Join("/", relPath)), nil
 }
 
+// checkNoSymlink returns an error if target, or one of its parents below root, is a
+// symbolic link: writing there would follow a link extracted by an earlier entry.
+// Missing path elements are fine, they are created as directories.
+func checkNoSymlink(root, target string) error {
+	root = filepath.Clean(root)
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == "." {
+		return err
+	}
+
+	cur := root
+	for _, elem := range strings.Split(rel, string(filepath.Separator)) {
+		cur = filepath.Join(cur, elem)
+		info, err := os.Lstat(cur)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("(zipslip) path goes through a symlink %q", cur)
+		}
+	}
+	return nil
+}
+
+// safeSymlink creates the symbolic link target pointing to dest, if dest resolves
+// inside root and no parent of target is a symbolic link.
+func safeSymlink(root, target, dest string) error {
+	resolved := filepath.Join(filepath.Dir(target), dest)
+	rel, err := filepath.Rel(filepath.Clean(root), resolved)
+	if filepath.IsAbs(dest) || err != nil || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("(zipslip) symlink target is unsafe %q", dest)
+	}
+	if err := checkNoSymlink(root, filepath.Dir(target)); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil { //nolint:gosec
+		return err
+	}
+	_ = os.Remove(target)
+	return os.Symlink(dest, target)
+}
+
 // escapeCSVField change `\"` to `\"\"` when field contains delimiter.
 func escapeCSVField(field string, delimiter rune) string {
 	// change `"` -> `""`
@@ -150,3 +201,201 @@ func isCsvSupportedType(val any) bool {
 		return false
 	}
 }
+
+// splitLines splits buf into lines without their trailing newline. A last line
+// without newline is kept.
+func splitLines(buf []byte) []string {
+	var (
+		lineBeg int // The begin of line
+		lines   []string
+	)
+	for idx, bVal := range buf {
+		if bVal == '\n' {
+			lines = append(lines, string(buf[lineBeg:idx]))
+			lineBeg = idx + 1
+		}
+	}
+
+	// Handle the last line of block
+	if lineBeg < len(buf) {
+		lines = append(lines, string(buf[lineBeg:]))
+	}
+	return lines
+}
+
+// chunkReader reads a file in line-aligned chunks for ParallelChunkRead
+type chunkReader struct {
+	file      *os.File
+	size      int64 // file size
+	chunkSize int64 // nominal chunk size, chunks are extended to the next line boundary
+	bufPool   sync.Pool
+}
+
+// run reads every chunk with up to workers goroutines and sends the non-empty
+// ones to out, in file order if ordered is true.
+func (r *chunkReader) run(ctx context.Context, out chan<- Chunk, workers int, ordered bool) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	numChunks := int((r.size + r.chunkSize - 1) / r.chunkSize)
+
+	// In order mode the window bounds how far the readers get ahead of the
+	// oldest chunk not sent yet, and so the chunks held in memory
+	var window chan struct{}
+	if ordered {
+		window = make(chan struct{}, 2*workers)
+	}
+
+	// Allocate tasks by sending chunk indexes to the readers
+	seqs := make(chan int)
+	go func() {
+		defer close(seqs)
+		for seq := range numChunks {
+			if window != nil {
+				select {
+				case window <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case seqs <- seq:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	results := make(chan Chunk)
+	var wg sync.WaitGroup
+	for range min(workers, numChunks) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for seq := range seqs {
+				chunk, err := r.read(seq)
+				if err != nil {
+					cancel(err) // the first error stops everything
+					return
+				}
+				select {
+				case results <- chunk:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	send := func(chunk Chunk) bool {
+		if len(chunk.Lines) == 0 {
+			return true // no line starts in this chunk
+		}
+		select {
+		case out <- chunk:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	pending := make(map[int]Chunk) // chunks read ahead of the next one to send
+	next := 0
+loop:
+	for chunk := range results {
+		if !ordered {
+			if !send(chunk) {
+				break
+			}
+			continue
+		}
+
+		pending[chunk.Seq] = chunk
+		for {
+			chunk, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			<-window
+
+			if !send(chunk) {
+				break loop
+			}
+		}
+	}
+
+	// The cause is the first read error or the error of ctx
+	var err error
+	if ctx.Err() != nil {
+		err = context.Cause(ctx)
+	}
+
+	// Let the readers exit before the file is closed
+	cancel(nil)
+	for range results {
+	}
+	return err
+}
+
+// read reads the lines of chunk seq: those starting in [seq*chunkSize, (seq+1)*chunkSize).
+func (r *chunkReader) read(seq int) (Chunk, error) {
+	start, err := r.align(int64(seq) * r.chunkSize)
+	if err != nil {
+		return Chunk{}, err
+	}
+	end, err := r.align(int64(seq+1) * r.chunkSize)
+	if err != nil {
+		return Chunk{}, err
+	}
+
+	chunk := Chunk{Seq: seq, Offset: start}
+	if start >= end {
+		return chunk, nil
+	}
+
+	temp, ok := r.bufPool.Get().(*[]byte)
+	if !ok {
+		return Chunk{}, errors.New("failed to get buffer from pool")
+	}
+	defer r.bufPool.Put(temp)
+
+	if int64(cap(*temp)) < end-start {
+		*temp = make([]byte, end-start)
+	}
+	buf := (*temp)[:end-start]
+	if _, err := r.file.ReadAt(buf, start); err != nil && !errors.Is(err, io.EOF) {
+		return Chunk{}, err
+	}
+
+	chunk.Lines = splitLines(buf)
+	return chunk, nil
+}
+
+// align returns the offset of the first line starting at or after off.
+func (r *chunkReader) align(off int64) (int64, error) {
+	if off <= 0 {
+		return 0, nil
+	}
+	if off >= r.size {
+		return r.size, nil
+	}
+
+	// A line starts at off if the previous byte ends a line
+	buf := make([]byte, 4096)
+	for pos := off - 1; pos < r.size; pos += int64(len(buf)) {
+		n, err := r.file.ReadAt(buf, pos)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return pos + int64(i) + 1, nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+	}
+	return r.size, nil
+}
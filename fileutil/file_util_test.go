@@ -2,6 +2,7 @@ package fileutil
 
 import (
 	"archive/zip"
+	"context"
 	"encoding/csv"
 	"errors"
 	"fmt"
@@ -12,6 +13,9 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -1671,3 +1675,124 @@ func TestReadFile(t *testing.T) {
 		t.Error("ReadFile() expected error, got nil")
 	}
 }
+
+// writeLinesFile writes lines to a temp file, each ending with a newline
+// except the last one.
+func writeLinesFile(t *testing.T, lines []string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "lines.txt")
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return file
+}
+
+// testLines returns n lines of varying length, totaling a few MB for large n.
+func testLines(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d %s", i, strings.Repeat("x", i%97))
+	}
+	return lines
+}
+
+func TestChunkRead(t *testing.T) {
+	file := writeLinesFile(t, []string{"a", "bb", "ccc"})
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+
+	bufPool := &sync.Pool{New: func() any {
+		buf := make([]byte, 0, 64)
+		return &buf
+	}}
+
+	// The block extends past the end of the file
+	lines, err := ChunkRead(f, 2, 64, bufPool)
+	if err != nil {
+		t.Fatalf("ChunkRead() error = %v", err)
+	}
+	if !reflect.DeepEqual(lines, []string{"bb", "ccc"}) {
+		t.Errorf("ChunkRead() = %q, want %q", lines, []string{"bb", "ccc"})
+	}
+}
+
+func TestParallelChunkRead(t *testing.T) {
+	want := testLines(60000) // about 3.4MB
+	file := writeLinesFile(t, want)
+
+	for _, ordered := range []bool{true, false} {
+		chChunks := make(chan Chunk)
+		errCh := make(chan error, 1)
+		go func() { errCh <- ParallelChunkRead(context.Background(), file, chChunks, 1, 4, ordered) }()
+
+		var chunks []Chunk
+		for chunk := range chChunks {
+			chunks = append(chunks, chunk)
+		}
+		if err := <-errCh; err != nil {
+			t.Fatalf("ParallelChunkRead() error = %v", err)
+		}
+
+		if ordered && !sort.SliceIsSorted(chunks, func(i, j int) bool { return chunks[i].Seq < chunks[j].Seq }) {
+			t.Errorf("ParallelChunkRead() ordered chunks out of order")
+		}
+		sort.Slice(chunks, func(i, j int) bool { return chunks[i].Seq < chunks[j].Seq })
+
+		var got []string
+		for _, chunk := range chunks {
+			got = append(got, chunk.Lines...)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ParallelChunkRead(ordered=%v) got %d lines, want %d unbroken lines", ordered, len(got), len(want))
+		}
+		if len(chunks) != 4 {
+			t.Errorf("ParallelChunkRead() sent %d chunks, want 4", len(chunks))
+		}
+	}
+}
+
+func TestParallelChunkRead_LongLine(t *testing.T) {
+	long := strings.Repeat("y", 2*1024*1024+10) // spans three 1MB chunks
+	want := []string{"first", long, "last"}
+	file := writeLinesFile(t, want)
+
+	chChunks := make(chan Chunk, 8)
+	if err := ParallelChunkRead(context.Background(), file, chChunks, 1, 2, true); err != nil {
+		t.Fatalf("ParallelChunkRead() error = %v", err)
+	}
+
+	var got []string
+	for chunk := range chChunks {
+		got = append(got, chunk.Lines...)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParallelChunkRead() got %d lines, want the long line unbroken", len(got))
+	}
+}
+
+func TestParallelChunkRead_Errors(t *testing.T) {
+	chChunks := make(chan Chunk)
+	if err := ParallelChunkRead(context.Background(), "/path/to/non/existing/file", chChunks, 1, 2, false); err == nil {
+		t.Errorf("ParallelChunkRead() expected error, got nil")
+	}
+	if _, ok := <-chChunks; ok {
+		t.Errorf("ParallelChunkRead() expected the channel to be closed")
+	}
+
+	// Cancellation stops reading even if nobody consumes the chunks
+	file := writeLinesFile(t, testLines(60000))
+	ctx, cancel := context.WithCancel(context.Background())
+	chChunks = make(chan Chunk)
+	errCh := make(chan error, 1)
+	go func() { errCh <- ParallelChunkRead(ctx, file, chChunks, 1, 2, true) }()
+
+	<-chChunks
+	cancel()
+	if err := <-errCh; !errors.Is(err, context.Canceled) {
+		t.Errorf("ParallelChunkRead() error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,301 @@
+package fileutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+// WritableFile is a file of a WritableFS open for writing
+type WritableFile interface {
+	fs.File
+	io.Writer
+}
+
+// WritableFS is a file system that can be written as well as read. As with fs.FS,
+// names are slash separated paths relative to the root of the file system, without
+// "." or ".." elements.
+//
+// DirFS and NewMemFS provide implementations over the OS and in memory, and AferoFS
+// adapts any afero file system.
+type WritableFS interface {
+	fs.StatFS
+	fs.ReadDirFS
+
+	// OpenFile opens the named file with the os.OpenFile flags and permissions.
+	OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error)
+	// MkdirAll creates the named directory along with any missing parent.
+	MkdirAll(name string, perm fs.FileMode) error
+	// Remove removes the named file or empty directory.
+	Remove(name string) error
+	// RemoveAll removes the named path and any children it contains.
+	RemoveAll(name string) error
+	// Rename renames oldname to newname, replacing newname if it exists.
+	Rename(oldname, newname string) error
+}
+
+// DirFS returns a WritableFS over the OS directory dir, the writable counterpart of
+// os.DirFS.
+func DirFS(dir string) WritableFS {
+	return AferoFS(afero.NewBasePathFs(afero.NewOsFs(), dir))
+}
+
+// NewMemFS returns an empty in-memory WritableFS, typically for tests.
+func NewMemFS() WritableFS {
+	return AferoFS(afero.NewMemMapFs())
+}
+
+// AferoFS adapts an afero file system to WritableFS.
+func AferoFS(fsys afero.Fs) WritableFS {
+	return aferoFS{fs: fsys, iofs: afero.NewIOFS(fsys)}
+}
+
+// aferoFS implements WritableFS over an afero file system
+type aferoFS struct {
+	fs   afero.Fs
+	iofs afero.IOFS
+}
+
+func (a aferoFS) Open(name string) (fs.File, error) { return a.iofs.Open(name) }
+
+func (a aferoFS) ReadDir(name string) ([]fs.DirEntry, error) { return a.iofs.ReadDir(name) }
+
+func (a aferoFS) ReadFile(name string) ([]byte, error) { return a.iofs.ReadFile(name) }
+
+func (a aferoFS) Stat(name string) (fs.FileInfo, error) {
+	if err := checkPath("stat", name); err != nil {
+		return nil, err
+	}
+	return a.fs.Stat(name)
+}
+
+func (a aferoFS) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	if err := checkPath("open", name); err != nil {
+		return nil, err
+	}
+	return a.fs.OpenFile(name, flag, perm)
+}
+
+func (a aferoFS) MkdirAll(name string, perm fs.FileMode) error {
+	if err := checkPath("mkdir", name); err != nil {
+		return err
+	}
+	return a.fs.MkdirAll(name, perm)
+}
+
+func (a aferoFS) Remove(name string) error {
+	if err := checkPath("remove", name); err != nil {
+		return err
+	}
+	return a.fs.Remove(name)
+}
+
+func (a aferoFS) RemoveAll(name string) error {
+	if err := checkPath("removeall", name); err != nil {
+		return err
+	}
+	return a.fs.RemoveAll(name)
+}
+
+func (a aferoFS) Rename(oldname, newname string) error {
+	if err := checkPath("rename", oldname); err != nil {
+		return err
+	}
+	if err := checkPath("rename", newname); err != nil {
+		return err
+	}
+	return a.fs.Rename(oldname, newname)
+}
+
+// osFS implements WritableFS with the os package. Unlike DirFS, names are OS paths,
+// absolute or relative to the working directory: it backs the os-based helpers such as
+// CopyDir with their FS counterparts.
+//
+// The FS helpers join names with path.Join, so osPath splits the volume name off an OS
+// path and passes the rest with slashes. osFS puts both back together, which keeps
+// Windows drive letters and UNC shares intact.
+type osFS struct {
+	volume string // volume name of every name, such as "C:" or `\\host\share`
+}
+
+// osPath returns the osFS and the slash separated name for the OS path name.
+func osPath(name string) (osFS, string) {
+	volume := filepath.VolumeName(name)
+	rest := filepath.ToSlash(name[len(volume):])
+	if rest == "" && len(volume) > 2 {
+		rest = "/" // a UNC share is a root, unlike a drive letter alone
+	}
+	return osFS{volume: volume}, rest
+}
+
+// path returns the OS path of name.
+func (o osFS) path(name string) string { return o.volume + filepath.FromSlash(name) }
+
+func (o osFS) Open(name string) (fs.File, error) {
+	f, err := os.Open(o.path(name)) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (o osFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(o.path(name)) }
+
+func (o osFS) Stat(name string) (fs.FileInfo, error) { return os.Stat(o.path(name)) }
+
+func (o osFS) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	f, err := os.OpenFile(o.path(name), flag, perm) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (o osFS) MkdirAll(name string, perm fs.FileMode) error { return os.MkdirAll(o.path(name), perm) }
+
+func (o osFS) Remove(name string) error { return os.Remove(o.path(name)) }
+
+func (o osFS) RemoveAll(name string) error { return os.RemoveAll(o.path(name)) }
+
+func (o osFS) Rename(oldname, newname string) error {
+	return os.Rename(o.path(oldname), o.path(newname))
+}
+
+// checkPath rejects the names fs.FS does not accept.
+func checkPath(op, name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return nil
+}
+
+// ReadFileToStringFS is ReadFileToString over fsys.
+func ReadFileToStringFS(fsys fs.FS, file string) (string, error) {
+	vb, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return "", err
+	}
+
+	return string(vb), nil
+}
+
+// ReadCSVFS is ReadCSV over fsys.
+func ReadCSVFS(fsys fs.FS, file string, delimiter ...rune) ([][]string, error) {
+	f, err := fsys.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readCSVRecords(f, delimiter...)
+}
+
+// FilesCurDirFS is FilesCurDir over fsys.
+func FilesCurDirFS(fsys fs.FS, dir string) ([]string, error) {
+	vEntry, err := fs.ReadDir(fsys, dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var vfn []string
+	for _, entry := range vEntry {
+		if entry.IsDir() {
+			continue
+		}
+		// Links to directories are directories too
+		if entry.Type()&fs.ModeSymlink != 0 {
+			if info, err := fs.Stat(fsys, path.Join(dir, entry.Name())); err == nil && info.IsDir() {
+				continue
+			}
+		}
+		vfn = append(vfn, entry.Name())
+	}
+	return vfn, nil
+}
+
+// DirSizeFS is DirSize over fsys.
+func DirSizeFS(fsys fs.FS, dir string) (int64, error) {
+	var size int64
+
+	err := fs.WalkDir(fsys, dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// CopyFileFS copies the file src of srcFS to dst in dstFS, which can be the same file
+// system.
+func CopyFileFS(dstFS WritableFS, dst string, srcFS fs.FS, src string) error {
+	sf, err := srcFS.Open(src)
+	if err != nil {
+		return err
+	}
+	defer sf.Close()
+
+	df, err := dstFS.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
+	if err != nil {
+		return err
+	}
+	defer df.Close()
+
+	if _, err := io.Copy(df, sf); err != nil {
+		return err
+	}
+	return df.Close()
+}
+
+// CopyDirFS copies the directory src of srcFS, including all subdirectories and files,
+// to dst in dstFS, which can be the same file system. Copying an embed.FS to the disk
+// is CopyDirFS(DirFS(dir), ".", embedded, "assets").
+func CopyDirFS(dstFS WritableFS, dst string, srcFS fs.FS, src string) error {
+	srcInfo, err := fs.Stat(srcFS, src)
+	if err != nil {
+		return fmt.Errorf("failed to get source directory info: %w", err)
+	}
+	if !srcInfo.IsDir() {
+		return fmt.Errorf("source is not a directory: %s", src)
+	}
+
+	if err := dstFS.MkdirAll(dst, 0o775); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	vEntry, err := fs.ReadDir(srcFS, src)
+	if err != nil {
+		return fmt.Errorf("failed to read source directory: %w", err)
+	}
+
+	for _, entry := range vEntry {
+		_src := path.Join(src, entry.Name())
+		_dst := path.Join(dst, entry.Name())
+
+		if entry.IsDir() {
+			err = CopyDirFS(dstFS, _dst, srcFS, _src)
+		} else {
+			err = CopyFileFS(dstFS, _dst, srcFS, _src)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
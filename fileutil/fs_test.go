@@ -0,0 +1,169 @@
+package fileutil
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+func testMapFS() fstest.MapFS {
+	return fstest.MapFS{
+		"assets/a.txt":         {Data: []byte("alpha")},
+		"assets/data.csv":      {Data: []byte("a;b\n1;2\n")},
+		"assets/sub/b.txt":     {Data: []byte("bravo")},
+		"assets/sub/deep/c.go": {Data: []byte("package c")},
+	}
+}
+
+func TestReadHelpersFS(t *testing.T) {
+	fsys := testMapFS()
+
+	content, err := ReadFileToStringFS(fsys, "assets/a.txt")
+	if err != nil || content != "alpha" {
+		t.Errorf("ReadFileToStringFS() = %q, %v, want \"alpha\"", content, err)
+	}
+	if _, err := ReadFileToStringFS(fsys, "missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadFileToStringFS() error = %v, want fs.ErrNotExist", err)
+	}
+
+	records, err := ReadCSVFS(fsys, "assets/data.csv", ';')
+	if want := [][]string{{"a", "b"}, {"1", "2"}}; err != nil || !reflect.DeepEqual(records, want) {
+		t.Errorf("ReadCSVFS() = %v, %v, want %v", records, err, want)
+	}
+
+	files, err := FilesCurDirFS(fsys, "assets")
+	if want := []string{"a.txt", "data.csv"}; err != nil || !reflect.DeepEqual(files, want) {
+		t.Errorf("FilesCurDirFS() = %v, %v, want %v", files, err, want)
+	}
+	if files, err := FilesCurDirFS(fsys, "missing"); err != nil || files != nil {
+		t.Errorf("FilesCurDirFS() of a missing directory = %v, %v, want nil", files, err)
+	}
+
+	size, err := DirSizeFS(fsys, "assets")
+	if err != nil || size != 5+8+5+9 {
+		t.Errorf("DirSizeFS() = %d, %v, want %d", size, err, 5+8+5+9)
+	}
+}
+
+func TestFilesCurDir_SymlinkToDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("alpha"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+	if err := os.Symlink("sub", filepath.Join(dir, "link")); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+
+	// FilesCurDir and FilesCurDirFS share one implementation: links to directories
+	// are not files
+	want := []string{"a.txt"}
+	if files, err := FilesCurDir(dir); err != nil || !reflect.DeepEqual(files, want) {
+		t.Errorf("FilesCurDir() = %v, %v, want %v", files, err, want)
+	}
+	if files, err := FilesCurDirFS(os.DirFS(dir), "."); err != nil || !reflect.DeepEqual(files, want) {
+		t.Errorf("FilesCurDirFS() = %v, %v, want %v", files, err, want)
+	}
+}
+
+func TestMemFS(t *testing.T) {
+	fsys := NewMemFS()
+
+	if err := fsys.MkdirAll("dir/sub", 0o755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	f, err := fsys.OpenFile("dir/sub/file.txt", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	if _, err := f.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if content, err := fs.ReadFile(fsys, "dir/sub/file.txt"); err != nil || string(content) != "hello" {
+		t.Errorf("ReadFile() = %q, %v, want \"hello\"", content, err)
+	}
+	if info, err := fsys.Stat("dir/sub"); err != nil || !info.IsDir() {
+		t.Errorf("Stat() = %v, %v, want a directory", info, err)
+	}
+
+	if err := fsys.Rename("dir/sub/file.txt", "dir/moved.txt"); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+	if files, err := FilesCurDirFS(fsys, "dir"); err != nil || !reflect.DeepEqual(files, []string{"moved.txt"}) {
+		t.Errorf("FilesCurDirFS() = %v, %v, want [moved.txt]", files, err)
+	}
+	if err := fsys.Remove("dir/moved.txt"); err != nil {
+		t.Errorf("Remove() error = %v", err)
+	}
+	if err := fsys.RemoveAll("dir"); err != nil {
+		t.Errorf("RemoveAll() error = %v", err)
+	}
+	if _, err := fsys.Stat("dir"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat() after RemoveAll error = %v, want fs.ErrNotExist", err)
+	}
+
+	for _, name := range []string{"../escape", "/abs", "a/./b"} {
+		if _, err := fsys.OpenFile(name, os.O_CREATE|os.O_WRONLY, 0o644); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("OpenFile(%q) error = %v, want fs.ErrInvalid", name, err)
+		}
+	}
+}
+
+func TestCopyDirFS(t *testing.T) {
+	want := []string{"a.txt", "data.csv", "sub/b.txt", "sub/deep/c.go"}
+
+	t.Run("to memory", func(t *testing.T) {
+		mem := NewMemFS()
+		if err := CopyDirFS(mem, "copy", testMapFS(), "assets"); err != nil {
+			t.Fatalf("CopyDirFS() error = %v", err)
+		}
+
+		var got []string
+		err := fs.WalkDir(mem, "copy", func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				got = append(got, path[len("copy/"):])
+			}
+			return err
+		})
+		sort.Strings(got)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("CopyDirFS() copied %v, %v, want %v", got, err, want)
+		}
+		if content, err := ReadFileToStringFS(mem, "copy/sub/deep/c.go"); err != nil || content != "package c" {
+			t.Errorf("copied content = %q, %v", content, err)
+		}
+	})
+
+	t.Run("to disk", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := CopyDirFS(DirFS(dir), ".", testMapFS(), "assets"); err != nil {
+			t.Fatalf("CopyDirFS() error = %v", err)
+		}
+		if got := listFiles(t, dir); !reflect.DeepEqual(got, want) {
+			t.Errorf("CopyDirFS() copied %v, want %v", got, want)
+		}
+		if content, err := os.ReadFile(filepath.Join(dir, "sub", "b.txt")); err != nil || string(content) != "bravo" {
+			t.Errorf("copied content = %q, %v", content, err)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		if err := CopyDirFS(NewMemFS(), "copy", testMapFS(), "missing"); err == nil {
+			t.Error("CopyDirFS() expected error for a missing source")
+		}
+		if err := CopyDirFS(NewMemFS(), "copy", testMapFS(), "assets/a.txt"); err == nil {
+			t.Error("CopyDirFS() expected error for a file source")
+		}
+	})
+}
@@ -0,0 +1,299 @@
+package fileutil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// ErrStop can be returned by the callback of EachLine, EachCSVRecord and
+// ParallelEachLine to stop the iteration early without an error.
+var ErrStop = errors.New("fileutil: stop iteration")
+
+// readerBufSize is the size of the buffered readers used for streaming
+const readerBufSize = 64 * 1024
+
+// readerPool holds reusable buffered readers for streaming
+var readerPool = sync.Pool{
+	New: func() any { return bufio.NewReaderSize(nil, readerBufSize) },
+}
+
+// EachLine calls fn for every line of the file in order, with line numbers
+// starting at 1. The line excludes its "\n" or "\r\n" terminator.
+//
+// The file is streamed, so memory use does not depend on its size. line is
+// only valid until fn returns: it points into a reused buffer and must be
+// copied to be retained. Iteration stops at the first error returned by fn,
+// which is returned unless it is ErrStop, or once ctx is done.
+func EachLine(ctx context.Context, file string, fn func(lineNo int, line []byte) error) error {
+	f, err := os.Open(file) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	lr := newLineReader(f)
+	defer lr.release()
+
+	for lineNo := 1; ; lineNo++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		line, err := lr.next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := fn(lineNo, line); err != nil {
+			if errors.Is(err, ErrStop) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// EachCSVRecord calls fn for every record of the csv file in order, with the
+// line number the record starts on.
+//
+// The file is streamed and record is reused between calls, so it must be
+// copied to be retained. Iteration stops at the first parse error, at the
+// first error returned by fn, which is returned unless it is ErrStop, or once
+// ctx is done.
+//
+//	delimiter: specifies csv delimiter, ',' by default
+func EachCSVRecord(ctx context.Context, file string, fn func(lineNo int, record []string) error,
+	delimiter ...rune,
+) error {
+	f, err := os.Open(file) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	br, _ := readerPool.Get().(*bufio.Reader)
+	br.Reset(f)
+	defer func() {
+		br.Reset(nil)
+		readerPool.Put(br)
+	}()
+
+	reader := csv.NewReader(br)
+	reader.ReuseRecord = true
+	if len(delimiter) > 0 {
+		reader.Comma = delimiter[0]
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		lineNo, _ := reader.FieldPos(0)
+		if err := fn(lineNo, record); err != nil {
+			if errors.Is(err, ErrStop) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// LineBatch is a batch of consecutive lines handed out by ParallelEachLine
+type LineBatch struct {
+	Seq       int      // index of the batch, starting at 0
+	FirstLine int      // line number of Lines[0], starting at 1
+	Lines     [][]byte // lines without their terminator
+}
+
+// ParallelEachLine reads the file sequentially in batches of batchSize lines
+// and calls fn for the batches on up to workers goroutines, one per CPU if 0.
+// batchSize defaults to 1024 lines if 0.
+//
+// Batches may be processed out of order; use Seq or FirstLine to restore it.
+// The lines of a batch point into a pooled buffer that is reused once fn
+// returns, so they must be copied to be retained. The first error returned by
+// fn, or ctx.Err() once ctx is done, stops reading and is returned; ErrStop
+// stops without an error.
+func ParallelEachLine(ctx context.Context, file string, workers, batchSize int,
+	fn func(batch LineBatch) error,
+) error {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if batchSize <= 0 {
+		batchSize = 1024
+	}
+
+	f, err := os.Open(file) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// Workers process batches and give their buffers back to the pool
+	batches := make(chan *batchBuf, workers)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				if ctx.Err() == nil {
+					if err := fn(b.batch()); err != nil {
+						cancel(err)
+					}
+				}
+				batchPool.Put(b)
+			}
+		}()
+	}
+
+	readErr := readBatches(ctx, f, batchSize, batches)
+	close(batches)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		readErr = context.Cause(ctx)
+	}
+	if errors.Is(readErr, ErrStop) {
+		return nil
+	}
+	return readErr
+}
+
+// readBatches reads the lines of r into batches and sends them until EOF or
+// ctx is done.
+func readBatches(ctx context.Context, r io.Reader, batchSize int, batches chan<- *batchBuf) error {
+	lr := newLineReader(r)
+	defer lr.release()
+
+	lineNo := 0
+	for seq := 0; ; seq++ {
+		b, _ := batchPool.Get().(*batchBuf)
+		b.reset(seq, lineNo+1)
+
+		for len(b.ends) < batchSize {
+			line, err := lr.next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				batchPool.Put(b)
+				return err
+			}
+			b.add(line)
+			lineNo++
+		}
+
+		if len(b.ends) == 0 {
+			batchPool.Put(b)
+			return nil
+		}
+		select {
+		case batches <- b:
+		case <-ctx.Done():
+			batchPool.Put(b)
+			return ctx.Err()
+		}
+		if len(b.ends) < batchSize {
+			return nil // EOF reached while filling the batch
+		}
+	}
+}
+
+// batchBuf holds the lines of a LineBatch back to back in a single buffer
+type batchBuf struct {
+	seq       int
+	firstLine int
+	data      []byte
+	ends      []int    // end offset of every line in data
+	lines     [][]byte // reused by batch
+}
+
+var batchPool = sync.Pool{New: func() any { return new(batchBuf) }}
+
+func (b *batchBuf) reset(seq, firstLine int) {
+	b.seq, b.firstLine = seq, firstLine
+	b.data, b.ends = b.data[:0], b.ends[:0]
+}
+
+func (b *batchBuf) add(line []byte) {
+	b.data = append(b.data, line...)
+	b.ends = append(b.ends, len(b.data))
+}
+
+// batch slices the lines out of the buffer, once it no longer grows.
+func (b *batchBuf) batch() LineBatch {
+	b.lines = b.lines[:0]
+	start := 0
+	for _, end := range b.ends {
+		b.lines = append(b.lines, b.data[start:end:end])
+		start = end
+	}
+	return LineBatch{Seq: b.seq, FirstLine: b.firstLine, Lines: b.lines}
+}
+
+// lineReader reads lines from a pooled bufio.Reader, returning slices of its
+// buffer whenever a line fits in it.
+type lineReader struct {
+	br   *bufio.Reader
+	long []byte // accumulates lines longer than the reader buffer
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	br, _ := readerPool.Get().(*bufio.Reader)
+	br.Reset(r)
+	return &lineReader{br: br}
+}
+
+// next returns the next line without its terminator, valid until the next
+// call. It returns io.EOF after the last line.
+func (lr *lineReader) next() ([]byte, error) {
+	line, err := lr.br.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		lr.long = append(lr.long[:0], line...)
+		for errors.Is(err, bufio.ErrBufferFull) {
+			line, err = lr.br.ReadSlice('\n')
+			lr.long = append(lr.long, line...)
+		}
+		line = lr.long
+	}
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if len(line) == 0 && err != nil {
+		return nil, io.EOF
+	}
+
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	return bytes.TrimSuffix(line, []byte("\r")), nil
+}
+
+// release gives the buffered reader back to the pool.
+func (lr *lineReader) release() {
+	lr.br.Reset(nil)
+	readerPool.Put(lr.br)
+}
@@ -0,0 +1,170 @@
+package fileutil
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestEachLine(t *testing.T) {
+	long := strings.Repeat("z", 3*readerBufSize) // longer than the reader buffer
+	file := filepath.Join(t.TempDir(), "lines.txt")
+	if err := os.WriteFile(file, []byte("a\r\n\nb\n"+long+"\nlast"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	var (
+		got     []string
+		lineNos []int
+	)
+	err := EachLine(context.Background(), file, func(lineNo int, line []byte) error {
+		got = append(got, string(line))
+		lineNos = append(lineNos, lineNo)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EachLine() error = %v", err)
+	}
+
+	want := []string{"a", "", "b", long, "last"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EachLine() got %d lines, want %d", len(got), len(want))
+	}
+	if !reflect.DeepEqual(lineNos, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("EachLine() line numbers = %v", lineNos)
+	}
+}
+
+func TestEachLine_Stop(t *testing.T) {
+	file := writeLinesFile(t, testLines(100))
+
+	count := 0
+	err := EachLine(context.Background(), file, func(lineNo int, _ []byte) error {
+		count++
+		if lineNo == 10 {
+			return ErrStop
+		}
+		return nil
+	})
+	if err != nil || count != 10 {
+		t.Errorf("EachLine() = %v after %d lines, want nil after 10", err, count)
+	}
+
+	errBoom := errors.New("boom")
+	err = EachLine(context.Background(), file, func(int, []byte) error { return errBoom })
+	if !errors.Is(err, errBoom) {
+		t.Errorf("EachLine() error = %v, want %v", err, errBoom)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := EachLine(ctx, file, func(int, []byte) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("EachLine() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestEachCSVRecord(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "records.csv")
+	content := "name;note\nalice;\"multi\nline\"\nbob;plain\n"
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	var (
+		got     [][]string
+		lineNos []int
+	)
+	err := EachCSVRecord(context.Background(), file, func(lineNo int, record []string) error {
+		got = append(got, append([]string(nil), record...))
+		lineNos = append(lineNos, lineNo)
+		return nil
+	}, ';')
+	if err != nil {
+		t.Fatalf("EachCSVRecord() error = %v", err)
+	}
+
+	want := [][]string{{"name", "note"}, {"alice", "multi\nline"}, {"bob", "plain"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EachCSVRecord() = %q, want %q", got, want)
+	}
+	if !reflect.DeepEqual(lineNos, []int{1, 2, 4}) {
+		t.Errorf("EachCSVRecord() line numbers = %v, want [1 2 4]", lineNos)
+	}
+
+	if err := EachCSVRecord(context.Background(), "./test_data/test_read.csv", func(int, []string) error {
+		return ErrStop
+	}); err != nil {
+		t.Errorf("EachCSVRecord() error = %v, want nil after ErrStop", err)
+	}
+}
+
+func TestParallelEachLine(t *testing.T) {
+	want := testLines(10000)
+	file := writeLinesFile(t, want)
+
+	var (
+		mu      sync.Mutex
+		batches []LineBatch
+	)
+	err := ParallelEachLine(context.Background(), file, 4, 300, func(batch LineBatch) error {
+		// Lines are only valid during the call
+		lines := make([][]byte, len(batch.Lines))
+		for i, line := range batch.Lines {
+			lines[i] = append([]byte(nil), line...)
+		}
+		batch.Lines = lines
+
+		mu.Lock()
+		batches = append(batches, batch)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ParallelEachLine() error = %v", err)
+	}
+
+	sort.Slice(batches, func(i, j int) bool { return batches[i].Seq < batches[j].Seq })
+	var got []string
+	for i, batch := range batches {
+		if batch.Seq != i || batch.FirstLine != i*300+1 {
+			t.Errorf("Batch %d: Seq = %d, FirstLine = %d", i, batch.Seq, batch.FirstLine)
+		}
+		for _, line := range batch.Lines {
+			got = append(got, string(line))
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParallelEachLine() got %d lines, want %d", len(got), len(want))
+	}
+}
+
+func TestParallelEachLine_Errors(t *testing.T) {
+	file := writeLinesFile(t, testLines(10000))
+
+	errBoom := errors.New("boom")
+	err := ParallelEachLine(context.Background(), file, 2, 100, func(batch LineBatch) error {
+		if batch.Seq == 3 {
+			return errBoom
+		}
+		return nil
+	})
+	if !errors.Is(err, errBoom) {
+		t.Errorf("ParallelEachLine() error = %v, want %v", err, errBoom)
+	}
+
+	err = ParallelEachLine(context.Background(), file, 2, 100, func(LineBatch) error { return ErrStop })
+	if err != nil {
+		t.Errorf("ParallelEachLine() error = %v, want nil after ErrStop", err)
+	}
+
+	if err := ParallelEachLine(context.Background(), "/path/to/non/existing/file", 2, 100,
+		func(LineBatch) error { return nil }); err == nil {
+		t.Errorf("ParallelEachLine() expected error, got nil")
+	}
+}
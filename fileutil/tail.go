@@ -0,0 +1,278 @@
+package fileutil
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"iter"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTailPoll is how often Tail checks the file for new lines by default
+const defaultTailPoll = 250 * time.Millisecond
+
+// TailLine is a line yielded by Tail
+type TailLine struct {
+	Text   string // without the trailing '\r' and '\n'
+	Offset int64  // offset just past the line, where to resume from
+}
+
+// TailOptions configures TailWithOptions. The zero value polls every 250ms and does
+// not persist the offset.
+type TailOptions struct {
+	// PollInterval is how often the file is checked for new lines, rotation and
+	// truncation.
+	PollInterval time.Duration
+	// OffsetFile is where the offset past the last yielded line is saved whenever the
+	// tail catches up and when it stops, along with the identity of the file where the
+	// platform provides one. A saved offset is used instead of fromOffset, unless the
+	// file has been replaced since, which is then read from its beginning.
+	OffsetFile string
+}
+
+// Tail follows the file at path like "tail -F", yielding its lines from fromOffset,
+// or from the end of the file if fromOffset is negative, until ctx is done.
+//
+// Tail waits for a missing file to be created and reads it from its beginning. It
+// restarts from the beginning when the file is truncated and, when it is renamed and a
+// new file created in its place as log rotation does, finishes reading the old file
+// before moving to the new one. A last line without a newline is only yielded once the
+// file has been rotated.
+func Tail(ctx context.Context, path string, fromOffset int64) iter.Seq2[TailLine, error] {
+	return TailWithOptions(ctx, path, fromOffset, TailOptions{})
+}
+
+// TailWithOptions is Tail configured by opts.
+func TailWithOptions(ctx context.Context, path string, fromOffset int64, opts TailOptions) iter.Seq2[TailLine, error] {
+	return tail(ctx, path, fromOffset, opts, nil)
+}
+
+// tail is TailWithOptions, calling stepped if not nil every time the file has been
+// checked.
+func tail(
+	ctx context.Context, path string, fromOffset int64, opts TailOptions, stepped func(),
+) iter.Seq2[TailLine, error] {
+	return func(yield func(TailLine, error) bool) {
+		t := &tailer{path: path, opts: opts, from: fromOffset, saved: -1}
+		if t.opts.PollInterval <= 0 {
+			t.opts.PollInterval = defaultTailPoll
+		}
+
+		if opts.OffsetFile != "" {
+			off, id, err := loadTailOffset(opts.OffsetFile)
+			if err != nil {
+				yield(TailLine{}, err)
+				return
+			}
+			if off >= 0 {
+				t.from, t.fromID = off, id
+			}
+		}
+
+		defer t.close()
+		defer t.save()
+
+		ticker := time.NewTicker(t.opts.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			if !t.step(yield) {
+				return
+			}
+			if stepped != nil {
+				stepped()
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// tailer is the state of TailWithOptions
+type tailer struct {
+	path string
+	opts TailOptions
+
+	from    int64  // offset to open the file at, 0 once it has been opened
+	fromID  string // identity of the file from applies to, any file if empty
+	fil     *os.File
+	id      string // identity of fil
+	rd      *bufio.Reader
+	off     int64  // offset in fil of the next read
+	partial []byte // line read without its newline yet
+	saved   int64  // last saved offset, -1 before the first save
+}
+
+// step reads what is available and handles rotation and truncation, returning false
+// once the iteration must end.
+func (t *tailer) step(yield func(TailLine, error) bool) bool {
+	if t.fil == nil {
+		err := t.open()
+		if errors.Is(err, fs.ErrNotExist) {
+			// The file is read from its beginning once created
+			t.from = 0
+			return true
+		}
+		if err != nil {
+			return yield(TailLine{}, err)
+		}
+	}
+
+	for {
+		if !t.read(yield) {
+			return false
+		}
+
+		cur, err := t.fil.Stat()
+		if err != nil {
+			return yield(TailLine{}, err)
+		}
+		info, err := os.Stat(t.path)
+		switch {
+		case err == nil && !os.SameFile(cur, info):
+			// Rotated: what is left of the old file was read above and will not grow
+			if len(t.partial) > 0 && !t.yieldLine(nil, yield) {
+				return false
+			}
+			t.close()
+			if err := t.open(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return yield(TailLine{}, err)
+			}
+			if t.fil == nil {
+				return true
+			}
+		case cur.Size() < t.off:
+			if err := t.seek(0); err != nil {
+				return yield(TailLine{}, err)
+			}
+		default:
+			t.save()
+			return true
+		}
+	}
+}
+
+// open opens the file at t.from, at its end if negative and at its beginning if past
+// its end or if the file is not the one t.from was saved for.
+func (t *tailer) open() error {
+	f, err := os.Open(t.path) //nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	id := fileID(info)
+	off := t.from
+	if off < 0 {
+		off = info.Size()
+	}
+	if off > info.Size() || (t.fromID != "" && id != "" && t.fromID != id) {
+		off = 0
+	}
+
+	t.fil, t.id = f, id
+	if err := t.seek(off); err != nil {
+		t.close()
+		return err
+	}
+	t.from, t.fromID = 0, ""
+	return nil
+}
+
+func (t *tailer) seek(off int64) error {
+	if _, err := t.fil.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+
+	t.rd = bufio.NewReader(t.fil)
+	t.off = off
+	t.partial = nil
+	return nil
+}
+
+func (t *tailer) close() {
+	if t.fil != nil {
+		t.fil.Close()
+		t.fil, t.rd = nil, nil
+	}
+}
+
+// read yields the complete lines available, keeping a last partial line for later.
+func (t *tailer) read(yield func(TailLine, error) bool) bool {
+	for {
+		dat, err := t.rd.ReadBytes('\n')
+		t.off += int64(len(dat))
+		if err == io.EOF {
+			t.partial = append(t.partial, dat...)
+			return true
+		}
+		if err != nil {
+			return yield(TailLine{}, err)
+		}
+		if !t.yieldLine(dat, yield) {
+			return false
+		}
+	}
+}
+
+func (t *tailer) yieldLine(dat []byte, yield func(TailLine, error) bool) bool {
+	if len(t.partial) > 0 {
+		dat = append(t.partial, dat...)
+		t.partial = nil
+	}
+
+	line := strings.TrimRight(string(dat), "\r\n")
+	return yield(TailLine{Text: line, Offset: t.off}, nil)
+}
+
+// save writes the offset past the last complete line to the offset file, if any,
+// followed by the identity of the file.
+func (t *tailer) save() {
+	if t.opts.OffsetFile == "" || t.rd == nil {
+		return
+	}
+
+	off := t.off - int64(len(t.partial))
+	if off == t.saved {
+		return
+	}
+	content := strconv.FormatInt(off, 10)
+	if t.id != "" {
+		content += " " + t.id
+	}
+	if err := WriteFileAtomic(t.opts.OffsetFile, []byte(content), 0o644); err == nil {
+		t.saved = off
+	}
+}
+
+// loadTailOffset reads an offset saved by Tail and the identity of its file, -1 if
+// there is none. The identity is empty if it was not saved.
+func loadTailOffset(file string) (int64, string, error) {
+	content, err := os.ReadFile(file) //nolint:gosec
+	if errors.Is(err, fs.ErrNotExist) {
+		return -1, "", nil
+	}
+	if err != nil {
+		return 0, "", err
+	}
+
+	offset, id, _ := strings.Cut(strings.TrimSpace(string(content)), " ")
+	off, err := strconv.ParseInt(offset, 10, 64)
+	if err != nil {
+		return 0, "", err
+	}
+	return off, id, nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package fileutil
+
+import "io/fs"
+
+// fileID returns an empty identity: the platform does not expose one through
+// fs.FileInfo, so a saved offset applies to whatever file is at the path.
+func fileID(fs.FileInfo) string { return "" }
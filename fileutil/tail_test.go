@@ -0,0 +1,224 @@
+package fileutil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tailLines collects the lines of a tail in the background.
+type tailLines struct {
+	cancel context.CancelFunc
+	lines  chan TailLine
+	done   chan error
+	steps  atomic.Int64 // times the tail has checked the file
+}
+
+func startTail(t *testing.T, path string, fromOffset int64, opts TailOptions) *tailLines {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tl := &tailLines{cancel: cancel, lines: make(chan TailLine, 100), done: make(chan error, 1)}
+	opts.PollInterval = 5 * time.Millisecond
+	stepped := func() { tl.steps.Add(1) }
+	go func() {
+		defer close(tl.done)
+		for line, err := range tail(ctx, path, fromOffset, opts, stepped) {
+			if err != nil {
+				tl.done <- err
+				return
+			}
+			tl.lines <- line
+		}
+	}()
+	t.Cleanup(tl.stop)
+	return tl
+}
+
+// next waits for n lines and returns their text.
+func (tl *tailLines) next(t *testing.T, n int) []string {
+	t.Helper()
+
+	var got []string
+	for len(got) < n {
+		select {
+		case line := <-tl.lines:
+			got = append(got, line.Text)
+		case err := <-tl.done:
+			t.Fatalf("Tail() ended after %v: %v", got, err)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Tail() timed out after %v", got)
+		}
+	}
+	return got
+}
+
+// sync waits until the tail has checked the file from start to end since the call.
+func (tl *tailLines) sync(t *testing.T) {
+	t.Helper()
+
+	// A check in progress may have looked at the file before the call
+	want := tl.steps.Load() + 2
+	deadline := time.Now().Add(2 * time.Second)
+	for tl.steps.Load() < want {
+		if time.Now().After(deadline) {
+			t.Fatal("Tail() did not check the file in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (tl *tailLines) stop() {
+	tl.cancel()
+	for range tl.done { //nolint:revive
+	}
+}
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644) //nolint:gosec
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatalf("WriteString() error = %v", err)
+	}
+}
+
+func TestTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "old\n")
+
+	tl := startTail(t, path, 0, TailOptions{})
+	if got := tl.next(t, 1); !reflect.DeepEqual(got, []string{"old"}) {
+		t.Errorf("Tail() = %v, want [old]", got)
+	}
+
+	appendFile(t, path, "one\r\ntw")
+	appendFile(t, path, "o\n")
+	if got, want := tl.next(t, 2), []string{"one", "two"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Tail() = %v, want %v", got, want)
+	}
+
+	// Truncation restarts from the beginning
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatalf("Truncate() error = %v", err)
+	}
+	tl.sync(t)
+	appendFile(t, path, "three\n")
+	if got := tl.next(t, 1); !reflect.DeepEqual(got, []string{"three"}) {
+		t.Errorf("Tail() after truncation = %v, want [three]", got)
+	}
+
+	// Rotation finishes the old file, including a last line without newline
+	appendFile(t, path, "four\nfive")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+	appendFile(t, path, "six\n")
+	if got, want := tl.next(t, 3), []string{"four", "five", "six"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Tail() after rotation = %v, want %v", got, want)
+	}
+}
+
+func TestTail_Offsets(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	// The file does not exist yet
+	tl := startTail(t, path, -1, TailOptions{})
+	tl.sync(t)
+	appendFile(t, path, "a\n")
+	if got := tl.next(t, 1); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("Tail() = %v, want [a]", got)
+	}
+	tl.stop()
+
+	// From the end
+	tl = startTail(t, path, -1, TailOptions{})
+	tl.sync(t)
+	appendFile(t, path, "b\n")
+	if got := tl.next(t, 1); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("Tail() from the end = %v, want [b]", got)
+	}
+	tl.stop()
+
+	// From an offset, saved in the offset file
+	offsetFile := filepath.Join(dir, "app.offset")
+	tl = startTail(t, path, 2, TailOptions{OffsetFile: offsetFile})
+	if got := tl.next(t, 1); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("Tail() from offset 2 = %v, want [b]", got)
+	}
+	tl.stop()
+	if off, _, err := loadTailOffset(offsetFile); err != nil || off != 4 {
+		t.Errorf("saved offset = %d, %v, want 4", off, err)
+	}
+
+	// The saved offset wins over fromOffset
+	appendFile(t, path, "c\n")
+	tl = startTail(t, path, 0, TailOptions{OffsetFile: offsetFile})
+	if got := tl.next(t, 1); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Errorf("Tail() resumed = %v, want [c]", got)
+	}
+	tl.stop()
+	if off, _, err := loadTailOffset(offsetFile); err != nil || off != 6 {
+		t.Errorf("saved offset = %d, %v, want 6", off, err)
+	}
+
+	if err := os.WriteFile(offsetFile, []byte("bad"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	for _, err := range Tail(context.Background(), path, 0) {
+		if err != nil {
+			t.Errorf("Tail() error = %v", err)
+		}
+		break
+	}
+	for _, err := range TailWithOptions(context.Background(), path, 0, TailOptions{OffsetFile: offsetFile}) {
+		if err == nil {
+			t.Error("TailWithOptions() expected error for a bad offset file")
+		}
+		break
+	}
+}
+
+func TestTail_OffsetAfterRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	offsetFile := filepath.Join(dir, "app.offset")
+	appendFile(t, path, "old one\nold two\n")
+	if info, err := os.Stat(path); err != nil || fileID(info) == "" {
+		t.Skip("no file identity on this platform")
+	}
+
+	tl := startTail(t, path, 0, TailOptions{OffsetFile: offsetFile})
+	tl.next(t, 2)
+	tl.stop()
+
+	// Rotated while stopped: the saved offset belongs to the old file
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+	appendFile(t, path, "new one\nnew two\nnew three\n")
+
+	tl = startTail(t, path, 0, TailOptions{OffsetFile: offsetFile})
+	if got := tl.next(t, 1); !reflect.DeepEqual(got, []string{"new one"}) {
+		t.Errorf("Tail() after rotation = %v, want [new one]", got)
+	}
+	tl.stop()
+
+	// Legacy offset files without identity still apply
+	if err := os.WriteFile(offsetFile, []byte("8"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	tl = startTail(t, path, 0, TailOptions{OffsetFile: offsetFile})
+	if got := tl.next(t, 1); !reflect.DeepEqual(got, []string{"new two"}) {
+		t.Errorf("Tail() from a legacy offset = %v, want [new two]", got)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package fileutil
+
+import (
+	"io/fs"
+	"strconv"
+	"syscall"
+)
+
+// fileID returns the device and inode of the file as "dev:ino", which stays the same
+// across renames and changes when the file is replaced.
+func fileID(info fs.FileInfo) string {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	return strconv.FormatUint(uint64(st.Dev), 10) + ":" + strconv.FormatUint(uint64(st.Ino), 10) //nolint:gosec,unconvert
+}
@@ -0,0 +1,286 @@
+package fileutil
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Errors returned by UnZipWithOptions when an archive exceeds its limits
+var (
+	ErrZipTooLarge       = errors.New("fileutil: zip uncompressed size exceeds the limit")
+	ErrZipTooManyEntries = errors.New("fileutil: zip has too many entries")
+	ErrZipRatio          = errors.New("fileutil: zip entry compression ratio exceeds the limit")
+)
+
+// ratioGrace is the size up to which an entry is not subject to MaxRatio, since small
+// files of repetitive content legitimately compress very well
+const ratioGrace = 1 << 20
+
+// UnZipProgress is a progress report of UnZipWithOptions, delivered after every
+// extracted entry
+type UnZipProgress struct {
+	Entry        string // name of the entry just extracted
+	Entries      int    // entries extracted so far
+	TotalEntries int    // entries to extract
+	Bytes        int64  // file content written so far, in bytes
+	TotalBytes   int64  // uncompressed size declared by the entries to extract
+}
+
+// UnZipOptions configures UnZipWithOptions. The zero value has no limits.
+type UnZipOptions struct {
+	// MaxTotalSize limits the total uncompressed size of the extracted entries, in bytes.
+	MaxTotalSize int64
+	// MaxEntries limits the number of entries of the archive.
+	MaxEntries int
+	// MaxRatio limits the uncompressed to compressed size ratio of every entry
+	// larger than 1MB.
+	MaxRatio float64
+	// Include only extracts the entries matching one of these globs, see path.Match.
+	// A glob without "/" is matched against the base name of the entries too.
+	Include []string
+	// Exclude skips the entries matching one of these globs, after Include.
+	Exclude []string
+	// PreserveMode applies the permissions of the entries, instead of 0644 for files
+	// and 0755 for directories.
+	PreserveMode bool
+	// PreserveModTime applies the modification times of the entries.
+	PreserveModTime bool
+	// Progress is called after every extracted entry.
+	Progress func(UnZipProgress)
+}
+
+// UnZipWithOptions decompresses the zip file src to dst, enforcing the limits of opts.
+//
+// The limits are checked against the sizes declared by the archive before anything is
+// extracted, and against the bytes actually decompressed while extracting, so a forged
+// archive cannot exceed them either. Entries escaping dst, by their name, as
+// symbolic links or through a symbolic link extracted earlier, are rejected.
+func UnZipWithOptions(dst, src string, opts UnZipOptions) error {
+	zipReader, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = zipReader.Close() }()
+
+	if opts.MaxEntries > 0 && len(zipReader.File) > opts.MaxEntries {
+		return fmt.Errorf("%w: %d > %d", ErrZipTooManyEntries, len(zipReader.File), opts.MaxEntries)
+	}
+
+	var (
+		files      []*zip.File
+		totalBytes int64
+	)
+	for _, f := range zipReader.File {
+		ok, err := opts.match(f.Name)
+		if err != nil {
+			return err
+		}
+		if ok {
+			files = append(files, f)
+			totalBytes += int64(min(f.UncompressedSize64, 1<<62)) //nolint:gosec // clamped
+		}
+	}
+	if opts.MaxTotalSize > 0 && totalBytes > opts.MaxTotalSize {
+		return fmt.Errorf("%w: %d > %d bytes", ErrZipTooLarge, totalBytes, opts.MaxTotalSize)
+	}
+
+	x := &unzipper{dst: dst, opts: opts}
+	for i, f := range files {
+		if err := x.extract(f); err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(UnZipProgress{
+				Entry:        f.Name,
+				Entries:      i + 1,
+				TotalEntries: len(files),
+				Bytes:        x.written,
+				TotalBytes:   totalBytes,
+			})
+		}
+	}
+
+	// Directories get their times last, extracting their content changes them
+	if opts.PreserveModTime {
+		for i := len(x.dirs) - 1; i >= 0; i-- {
+			if err := os.Chtimes(x.dirs[i].path, time.Time{}, x.dirs[i].modTime); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// match reports whether the entry name passes the Include and Exclude globs.
+func (opts *UnZipOptions) match(name string) (bool, error) {
+	included := len(opts.Include) == 0
+	for _, pattern := range opts.Include {
+		ok, err := matchGlob(pattern, name)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false, nil
+	}
+
+	for _, pattern := range opts.Exclude {
+		ok, err := matchGlob(pattern, name)
+		if err != nil || ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchGlob matches the slash separated name against pattern, and its base name too
+// if pattern has no "/".
+func matchGlob(pattern, name string) (bool, error) {
+	name = strings.TrimSuffix(name, "/")
+	ok, err := path.Match(pattern, name)
+	if err != nil || ok || strings.Contains(pattern, "/") {
+		return ok, err
+	}
+	return path.Match(pattern, path.Base(name))
+}
+
+// unzipper extracts the entries of an archive one at a time
+type unzipper struct {
+	dst     string
+	opts    UnZipOptions
+	written int64 // bytes written so far
+
+	dirs []dirTime // extracted directories, to apply their times last
+}
+
+// dirTime is the modification time of an extracted directory
+type dirTime struct {
+	path    string
+	modTime time.Time
+}
+
+// extract writes the entry f under dst.
+func (x *unzipper) extract(f *zip.File) error {
+	target, err := safeFilepathJoin(x.dst, f.Name)
+	if err != nil {
+		return err
+	}
+
+	mode := f.Mode()
+	if mode&os.ModeSymlink == 0 {
+		if err := checkNoSymlink(x.dst, target); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case mode.IsDir():
+		perm := os.FileMode(0o755)
+		if x.opts.PreserveMode {
+			perm = mode.Perm() | 0o700 // the content must remain writable
+		}
+		if err := os.MkdirAll(target, perm); err != nil {
+			return err
+		}
+		if x.opts.PreserveModTime {
+			x.dirs = append(x.dirs, dirTime{path: target, modTime: f.Modified})
+		}
+		return nil
+
+	case mode&os.ModeSymlink != 0:
+		return x.extractSymlink(f, target)
+
+	case !mode.IsRegular():
+		return nil // devices, pipes and the like are skipped
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil { //nolint:gosec
+		return err
+	}
+
+	perm := os.FileMode(0o644)
+	if x.opts.PreserveMode {
+		perm = mode.Perm()
+	}
+	if err := x.extractFile(f, target, perm); err != nil {
+		return err
+	}
+
+	if x.opts.PreserveModTime {
+		return os.Chtimes(target, time.Time{}, f.Modified)
+	}
+	return nil
+}
+
+// extractFile copies the content of f to target, enforcing the size limits.
+func (x *unzipper) extractFile(f *zip.File, target string, perm os.FileMode) error {
+	in, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	// Never trust the declared size: copy at most one byte past the limit to detect it
+	limit := int64(-1)
+	if x.opts.MaxTotalSize > 0 {
+		limit = x.opts.MaxTotalSize - x.written
+	}
+	if x.opts.MaxRatio > 0 {
+		maxSize := max(int64(x.opts.MaxRatio*float64(f.CompressedSize64)), ratioGrace)
+		if limit < 0 || maxSize < limit {
+			limit = maxSize
+		}
+	}
+
+	var r io.Reader = in
+	if limit >= 0 {
+		r = io.LimitReader(in, limit+1)
+	}
+	n, err := io.Copy(out, r)
+	x.written += n
+	if err != nil {
+		return err
+	}
+
+	if limit >= 0 && n > limit {
+		if x.opts.MaxTotalSize > 0 && x.written > x.opts.MaxTotalSize {
+			return fmt.Errorf("%w: more than %d bytes", ErrZipTooLarge, x.opts.MaxTotalSize)
+		}
+		return fmt.Errorf("%w: more than %.0f times its compressed size", ErrZipRatio, x.opts.MaxRatio)
+	}
+	return out.Close()
+}
+
+// extractSymlink creates the symbolic link f at target, if it points inside dst.
+func (x *unzipper) extractSymlink(f *zip.File, target string) error {
+	in, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	link, err := io.ReadAll(io.LimitReader(in, 4096))
+	if err != nil {
+		return err
+	}
+
+	return safeSymlink(x.dst, target, string(link))
+}
@@ -0,0 +1,238 @@
+package fileutil
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+type testZipEntry struct {
+	name    string
+	content string
+	mode    os.FileMode
+}
+
+var testZipTime = time.Date(2020, 5, 6, 7, 8, 10, 0, time.UTC)
+
+func writeTestZip(t *testing.T, entries []testZipEntry) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "test.zip")
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Deflate, Modified: testZipTime}
+		mode := e.mode
+		if mode == 0 {
+			mode = 0o640
+		}
+		header.SetMode(mode)
+
+		fw, err := w.CreateHeader(header)
+		if err != nil {
+			t.Fatalf("CreateHeader() error = %v", err)
+		}
+		if _, err := fw.Write([]byte(e.content)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := os.WriteFile(file, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return file
+}
+
+// listFiles returns the regular files and links under dir, relative and slash separated.
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestUnZipWithOptions(t *testing.T) {
+	src := writeTestZip(t, []testZipEntry{
+		{name: "dir/", mode: os.ModeDir | 0o750},
+		{name: "dir/a.txt", content: "aaa", mode: 0o600},
+		{name: "dir/b.log", content: "bbb"},
+		{name: "c.txt", content: "ccc"},
+		{name: "link", content: "dir/a.txt", mode: os.ModeSymlink | 0o777},
+	})
+
+	dst := t.TempDir()
+	var reports []UnZipProgress
+	err := UnZipWithOptions(dst, src, UnZipOptions{
+		PreserveMode:    true,
+		PreserveModTime: true,
+		Progress:        func(p UnZipProgress) { reports = append(reports, p) },
+	})
+	if err != nil {
+		t.Fatalf("UnZipWithOptions() error = %v", err)
+	}
+
+	if got, want := listFiles(t, dst), []string{"c.txt", "dir/a.txt", "dir/b.log", "link"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("UnZipWithOptions() extracted %v, want %v", got, want)
+	}
+
+	info, err := os.Stat(filepath.Join(dst, "dir", "a.txt"))
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(testZipTime) {
+		t.Errorf("modtime = %v, want %v", info.ModTime(), testZipTime)
+	}
+	if info, err := os.Stat(filepath.Join(dst, "dir")); err != nil || !info.ModTime().Equal(testZipTime) {
+		t.Errorf("directory modtime = %v, %v, want %v", info.ModTime(), err, testZipTime)
+	}
+
+	if content, err := os.ReadFile(filepath.Join(dst, "link")); err != nil || string(content) != "aaa" {
+		t.Errorf("symlink content = %q, %v, want \"aaa\"", content, err)
+	}
+
+	if len(reports) != 5 {
+		t.Fatalf("Progress called %d times, want 5", len(reports))
+	}
+	last := reports[len(reports)-1]
+	if last.Entries != 5 || last.TotalEntries != 5 || last.Bytes != 9 {
+		t.Errorf("last progress = %+v", last)
+	}
+}
+
+func TestUnZipWithOptions_Globs(t *testing.T) {
+	src := writeTestZip(t, []testZipEntry{
+		{name: "dir/a.txt", content: "a"},
+		{name: "dir/b.log", content: "b"},
+		{name: "dir/sub/c.txt", content: "c"},
+		{name: "d.txt", content: "d"},
+	})
+
+	tests := []struct {
+		name string
+		opts UnZipOptions
+		want []string
+	}{
+		{"include base name", UnZipOptions{Include: []string{"*.txt"}}, []string{"d.txt", "dir/a.txt", "dir/sub/c.txt"}},
+		{"include path", UnZipOptions{Include: []string{"dir/*"}}, []string{"dir/a.txt", "dir/b.log"}},
+		{"exclude", UnZipOptions{Exclude: []string{"*.txt"}}, []string{"dir/b.log"}},
+		{
+			"include and exclude",
+			UnZipOptions{Include: []string{"*.txt"}, Exclude: []string{"dir/sub/*"}},
+			[]string{"d.txt", "dir/a.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := t.TempDir()
+			if err := UnZipWithOptions(dst, src, tt.opts); err != nil {
+				t.Fatalf("UnZipWithOptions() error = %v", err)
+			}
+			if got := listFiles(t, dst); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UnZipWithOptions() extracted %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if err := UnZipWithOptions(t.TempDir(), src, UnZipOptions{Include: []string{"["}}); err == nil {
+		t.Error("UnZipWithOptions() expected error for a bad pattern")
+	}
+}
+
+func TestUnZipWithOptions_Limits(t *testing.T) {
+	zeros := string(make([]byte, 4<<20)) // compresses about 1000 times
+	src := writeTestZip(t, []testZipEntry{
+		{name: "small.txt", content: "hello"},
+		{name: "zeros.bin", content: zeros},
+	})
+
+	tests := []struct {
+		name string
+		opts UnZipOptions
+		want error
+	}{
+		{"entries", UnZipOptions{MaxEntries: 1}, ErrZipTooManyEntries},
+		{"total size", UnZipOptions{MaxTotalSize: 1 << 20}, ErrZipTooLarge},
+		{"ratio", UnZipOptions{MaxRatio: 100}, ErrZipRatio},
+		{"within limits", UnZipOptions{MaxEntries: 2, MaxTotalSize: 8 << 20, MaxRatio: 10000}, nil},
+		{"excluded entries do not count", UnZipOptions{MaxTotalSize: 10, Exclude: []string{"*.bin"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := UnZipWithOptions(t.TempDir(), src, tt.opts)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("UnZipWithOptions() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnZipWithOptions_Unsafe(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry testZipEntry
+	}{
+		{"parent path", testZipEntry{name: "../evil.txt", content: "x"}},
+		{"absolute symlink", testZipEntry{name: "link", content: "/etc/passwd", mode: os.ModeSymlink | 0o777}},
+		{"escaping symlink", testZipEntry{name: "a/link", content: "../../x", mode: os.ModeSymlink | 0o777}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := writeTestZip(t, []testZipEntry{tt.entry})
+			dst := t.TempDir()
+			if err := UnZipWithOptions(dst, src, UnZipOptions{}); err == nil {
+				t.Error("UnZipWithOptions() expected error for an unsafe entry")
+			}
+			if files := listFiles(t, dst); len(files) != 0 {
+				t.Errorf("UnZipWithOptions() extracted %v", files)
+			}
+		})
+	}
+}
+
+func TestUnZip_SymlinkChain(t *testing.T) {
+	// s -> dst, s/esc -> dst/.. : the file entry would land next to dst
+	src := writeTestZip(t, []testZipEntry{
+		{name: "s", content: ".", mode: os.ModeSymlink | 0o777},
+		{name: "s/esc", content: "..", mode: os.ModeSymlink | 0o777},
+		{name: "s/esc/pwned.txt", content: "x"},
+	})
+
+	for name, unzip := range map[string]func(dst, src string) error{
+		"UnZip":            UnZip,
+		"UnZipWithOptions": func(dst, src string) error { return UnZipWithOptions(dst, src, UnZipOptions{}) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			parent := t.TempDir()
+			dst := filepath.Join(parent, "dst")
+			if err := unzip(dst, src); err == nil {
+				t.Errorf("%s() expected error for an entry under a symlink", name)
+			}
+			if _, err := os.Lstat(filepath.Join(parent, "pwned.txt")); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("%s() wrote outside dst: %v", name, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,493 @@
+package fileutil
+
+import (
+	"archive/zip"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// SymlinkPolicy tells Walk what to do with symbolic links
+type SymlinkPolicy int
+
+const (
+	// SymlinkReport reports symbolic links as such, without following them
+	SymlinkReport SymlinkPolicy = iota
+	// SymlinkFollow reports symbolic links as their target and walks the directories
+	// they point to, skipping those that would form a cycle
+	SymlinkFollow
+	// SymlinkSkip ignores symbolic links
+	SymlinkSkip
+)
+
+// WalkOptions filters the entries of Walk, CopyDirWithOptions, DirSizeWithOptions and
+// ZipWithOptions. The zero value walks everything.
+//
+// Patterns are slash separated globs matched against the path relative to the root, see
+// path.Match, where "**" matches any number of directories. A pattern without "/"
+// matches the base name at any depth.
+type WalkOptions struct {
+	// Include only reports the files matching one of these patterns. Directories are
+	// walked regardless.
+	Include []string
+	// Exclude skips the files and directories matching one of these patterns.
+	Exclude []string
+	// IgnoreFiles names files, such as ".gitignore", whose patterns exclude entries of
+	// the directory they are in and its subdirectories, with the .gitignore syntax.
+	IgnoreFiles []string
+	// MaxDepth limits the depth of the reported entries, 1 for the entries of the root.
+	// Zero means unlimited.
+	MaxDepth int
+	// Symlinks tells what to do with symbolic links, SymlinkReport by default.
+	Symlinks SymlinkPolicy
+	// IncludeDirs reports the directories too, before their content.
+	IncludeDirs bool
+	// Workers is the number of goroutines that stat the entries of a directory, one
+	// per CPU if 0.
+	Workers int
+}
+
+// WalkEntry is a file or directory reported by Walk
+type WalkEntry struct {
+	Path  string      // path of the entry, root joined with Rel
+	Rel   string      // slash separated path relative to the root
+	Depth int         // 1 for the entries of the root
+	Info  fs.FileInfo // of the link target for links followed with SymlinkFollow
+}
+
+// Walk walks the file tree rooted at root in lexical order, yielding the entries that
+// pass opts. The root itself is not reported.
+//
+// An error reading a directory is yielded with the entry of the directory and the walk
+// may go on without it; invalid options or an unreadable root end the walk.
+func Walk(root string, opts WalkOptions) iter.Seq2[WalkEntry, error] {
+	return func(yield func(WalkEntry, error) bool) {
+		w, err := newWalker(opts)
+		if err != nil {
+			yield(WalkEntry{Path: root}, err)
+			return
+		}
+
+		info, err := os.Stat(root)
+		if err != nil {
+			yield(WalkEntry{Path: root}, err)
+			return
+		}
+		if !info.IsDir() {
+			yield(WalkEntry{Path: root}, errors.New("walk root is not a directory: "+root))
+			return
+		}
+
+		w.walk(WalkEntry{Path: root, Info: info}, nil, []fs.FileInfo{info}, yield)
+	}
+}
+
+// walker holds the compiled options of a Walk
+type walker struct {
+	opts    WalkOptions
+	include []globPattern
+	exclude []globPattern
+}
+
+func newWalker(opts WalkOptions) (*walker, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+
+	w := &walker{opts: opts}
+	for _, p := range opts.Include {
+		g, err := compileGlob(p)
+		if err != nil {
+			return nil, err
+		}
+		w.include = append(w.include, g)
+	}
+	for _, p := range opts.Exclude {
+		g, err := compileGlob(p)
+		if err != nil {
+			return nil, err
+		}
+		w.exclude = append(w.exclude, g)
+	}
+	return w, nil
+}
+
+// walk yields the content of the directory dir. ignores are the ignore rules in effect
+// and ancestors the directories from the root to dir, to detect cycles. It returns
+// false once yield asks to stop.
+func (w *walker) walk(dir WalkEntry, ignores []ignoreRule, ancestors []fs.FileInfo,
+	yield func(WalkEntry, error) bool,
+) bool {
+	dirEntries, err := os.ReadDir(dir.Path)
+	if err != nil {
+		return yield(dir, err)
+	}
+
+	ignores, err = w.loadIgnores(dir, ignores)
+	if err != nil && !yield(dir, err) {
+		return false
+	}
+
+	// Filter by name first, then stat the survivors in parallel
+	entries := make([]WalkEntry, 0, len(dirEntries))
+	types := make([]fs.FileMode, 0, len(dirEntries))
+	for _, d := range dirEntries {
+		rel := path.Join(dir.Rel, d.Name())
+		isDir := d.IsDir()
+		if d.Type()&fs.ModeSymlink != 0 && w.opts.Symlinks == SymlinkSkip {
+			continue
+		}
+		if matchAny(w.exclude, rel) || isIgnored(ignores, rel, isDir) {
+			continue
+		}
+		entries = append(entries, WalkEntry{
+			Path:  filepath.Join(dir.Path, d.Name()),
+			Rel:   rel,
+			Depth: dir.Depth + 1,
+		})
+		types = append(types, d.Type())
+	}
+	errs := w.stat(entries, types)
+
+	for i, entry := range entries {
+		if errs[i] != nil {
+			if !yield(entry, errs[i]) {
+				return false
+			}
+			continue
+		}
+
+		if !entry.Info.IsDir() {
+			if (len(w.include) == 0 || matchAny(w.include, entry.Rel)) && !yield(entry, nil) {
+				return false
+			}
+			continue
+		}
+
+		// A followed link to a directory may point to one of its ancestors
+		if types[i]&fs.ModeSymlink != 0 && isAncestor(ancestors, entry.Info) {
+			continue
+		}
+		// Links followed to directories can be excluded as directories too
+		if types[i]&fs.ModeSymlink != 0 && isIgnored(ignores, entry.Rel, true) {
+			continue
+		}
+
+		if w.opts.IncludeDirs && !yield(entry, nil) {
+			return false
+		}
+		if w.opts.MaxDepth > 0 && entry.Depth >= w.opts.MaxDepth {
+			continue
+		}
+		if !w.walk(entry, ignores, append(ancestors, entry.Info), yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// stat fills the Info of entries on up to Workers goroutines, returning the errors.
+func (w *walker) stat(entries []WalkEntry, types []fs.FileMode) []error {
+	errs := make([]error, len(entries))
+	statOne := func(i int) {
+		if types[i]&fs.ModeSymlink != 0 && w.opts.Symlinks == SymlinkFollow {
+			entries[i].Info, errs[i] = os.Stat(entries[i].Path)
+		} else {
+			entries[i].Info, errs[i] = os.Lstat(entries[i].Path)
+		}
+	}
+
+	workers := min(w.opts.Workers, len(entries))
+	if workers <= 1 {
+		for i := range entries {
+			statOne(i)
+		}
+		return errs
+	}
+
+	var wg sync.WaitGroup
+	for worker := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := worker; i < len(entries); i += workers {
+				statOne(i)
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// loadIgnores returns ignores extended with the rules of the ignore files of dir.
+func (w *walker) loadIgnores(dir WalkEntry, ignores []ignoreRule) ([]ignoreRule, error) {
+	var errs []error
+	for _, name := range w.opts.IgnoreFiles {
+		rules, err := readIgnoreFile(filepath.Join(dir.Path, name), dir.Rel)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(rules) > 0 {
+			// Copy so sibling directories do not share the appended rules
+			ignores = append(ignores[:len(ignores):len(ignores)], rules...)
+		}
+	}
+	return ignores, errors.Join(errs...)
+}
+
+func isAncestor(ancestors []fs.FileInfo, info fs.FileInfo) bool {
+	for _, ancestor := range ancestors {
+		if os.SameFile(ancestor, info) {
+			return true
+		}
+	}
+	return false
+}
+
+// globPattern is a compiled WalkOptions pattern
+type globPattern struct {
+	segments []string
+	anyDepth bool // the pattern has no "/" and matches base names
+}
+
+func compileGlob(pattern string) (globPattern, error) {
+	anyDepth := !strings.Contains(pattern, "/") // before a leading "/" anchors it
+	pattern = strings.TrimPrefix(pattern, "/")
+	g := globPattern{segments: strings.Split(pattern, "/"), anyDepth: anyDepth}
+	for _, segment := range g.segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return globPattern{}, err
+		}
+	}
+	return g, nil
+}
+
+// match reports whether the slash separated rel matches the pattern.
+func (g globPattern) match(rel string) bool {
+	if g.anyDepth {
+		return matchSegments(g.segments, []string{path.Base(rel)})
+	}
+	return matchSegments(g.segments, strings.Split(rel, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, "**" matching any
+// number of path segments.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := len(name); i >= 0; i-- {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+func matchAny(patterns []globPattern, rel string) bool {
+	for _, g := range patterns {
+		if g.match(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreRule is a pattern of an ignore file
+type ignoreRule struct {
+	base    string // slash separated directory of the ignore file, relative to the root
+	glob    globPattern
+	negate  bool // "!pattern" re-includes
+	dirOnly bool // "pattern/" only matches directories
+}
+
+// readIgnoreFile parses the ignore file, if it exists, of the directory base.
+func readIgnoreFile(file, base string) ([]ignoreRule, error) {
+	f, err := os.Open(file) //nolint:gosec
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate, line = true, line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`) // escaped leading "#" or "!"
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly, line = true, strings.TrimSuffix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// A pattern with a "/" other than a trailing one is anchored to the base
+		if rule.glob, err = compileGlob(line); err != nil {
+			continue // invalid patterns are ignored, as git does
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// isIgnored applies the ignore rules to rel, the last matching rule winning.
+func isIgnored(rules []ignoreRule, rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		sub := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			sub = rel[len(rule.base)+1:]
+		}
+		if rule.glob.match(sub) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// CopyDirWithOptions is CopyDir copying only the entries of src that pass opts, see
+// Walk. Symbolic links that are not followed are copied as links. Without Include
+// patterns, empty directories are copied too.
+func CopyDirWithOptions(dst, src string, opts WalkOptions) error {
+	opts.IncludeDirs = len(opts.Include) == 0
+	if err := os.MkdirAll(dst, 0o775); err != nil { //nolint:gosec
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	for entry, err := range Walk(src, opts) {
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, filepath.FromSlash(entry.Rel))
+		mode := entry.Info.Mode()
+		switch {
+		case mode.IsDir():
+			err = os.MkdirAll(target, 0o775) //nolint:gosec
+		case mode&fs.ModeSymlink != 0:
+			err = copySymlink(target, entry.Path)
+		default:
+			if err = os.MkdirAll(filepath.Dir(target), 0o775); err == nil { //nolint:gosec
+				err = CopyFile(target, entry.Path)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copySymlink creates the symbolic link dst pointing where src points.
+func copySymlink(dst, src string) error {
+	link, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o775); err != nil { //nolint:gosec
+		return err
+	}
+	return os.Symlink(link, dst)
+}
+
+// DirSizeWithOptions is DirSize counting only the files of dir that pass opts, see Walk.
+func DirSizeWithOptions(dir string, opts WalkOptions) (int64, error) {
+	opts.IncludeDirs = false
+
+	var size int64
+	for entry, err := range Walk(dir, opts) {
+		if err != nil {
+			return size, err
+		}
+		if entry.Info.Mode().IsRegular() {
+			size += entry.Info.Size()
+		}
+	}
+	return size, nil
+}
+
+// ZipWithOptions is Zip for a directory, compressing only the files of src that pass
+// opts, see Walk. Entries are named relative to src and symbolic links that are not
+// followed are stored as links.
+func ZipWithOptions(dst, src string, opts WalkOptions) error {
+	opts.IncludeDirs = false
+
+	return writeArchive(dst, func(w io.Writer) error {
+		archive := zip.NewWriter(w)
+		for entry, err := range Walk(src, opts) {
+			if err != nil {
+				return err
+			}
+			if err := addWalkEntryToZip(archive, entry); err != nil {
+				return err
+			}
+		}
+		return archive.Close()
+	})
+}
+
+// addWalkEntryToZip writes the file or symbolic link entry to archive.
+func addWalkEntryToZip(archive *zip.Writer, entry WalkEntry) error {
+	header, err := zip.FileInfoHeader(entry.Info)
+	if err != nil {
+		return err
+	}
+	header.Name = entry.Rel
+
+	if entry.Info.Mode()&fs.ModeSymlink != 0 {
+		link, err := os.Readlink(entry.Path)
+		if err != nil {
+			return err
+		}
+		writer, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(writer, link)
+		return err
+	}
+
+	header.Method = zip.Deflate
+	writer, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	return copyFileTo(writer, entry.Path)
+}
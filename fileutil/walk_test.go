@@ -0,0 +1,202 @@
+package fileutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeWalkTree creates the files, with their name as content, under a new directory.
+func writeWalkTree(t *testing.T, files ...string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for _, name := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+	return root
+}
+
+func walkRels(t *testing.T, root string, opts WalkOptions) []string {
+	t.Helper()
+
+	var rels []string
+	for entry, err := range Walk(root, opts) {
+		if err != nil {
+			t.Fatalf("Walk() error = %v", err)
+		}
+		rels = append(rels, entry.Rel)
+	}
+	return rels
+}
+
+func TestWalk(t *testing.T) {
+	root := writeWalkTree(t,
+		"a.go", "b.txt", "cmd/main.go", "cmd/main_test.go",
+		"internal/x/y.go", "vendor/dep/dep.go", "docs/readme.md",
+	)
+
+	tests := []struct {
+		name string
+		opts WalkOptions
+		want []string
+	}{
+		{"all", WalkOptions{}, []string{
+			"a.go", "b.txt", "cmd/main.go", "cmd/main_test.go", "docs/readme.md",
+			"internal/x/y.go", "vendor/dep/dep.go",
+		}},
+		{"include base name", WalkOptions{Include: []string{"*.go"}}, []string{
+			"a.go", "cmd/main.go", "cmd/main_test.go", "internal/x/y.go", "vendor/dep/dep.go",
+		}},
+		{"include double star", WalkOptions{Include: []string{"internal/**/*.go", "cmd/*.go"}}, []string{
+			"cmd/main.go", "cmd/main_test.go", "internal/x/y.go",
+		}},
+		{"exclude", WalkOptions{Include: []string{"**/*.go"}, Exclude: []string{"vendor", "*_test.go"}}, []string{
+			"a.go", "cmd/main.go", "internal/x/y.go",
+		}},
+		{"max depth", WalkOptions{MaxDepth: 1}, []string{"a.go", "b.txt"}},
+		{"dirs", WalkOptions{IncludeDirs: true, MaxDepth: 2, Exclude: []string{"internal", "vendor"}}, []string{
+			"a.go", "b.txt", "cmd", "cmd/main.go", "cmd/main_test.go", "docs", "docs/readme.md",
+		}},
+		{"sequential", WalkOptions{Workers: 1, Include: []string{"*.md"}}, []string{"docs/readme.md"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := walkRels(t, root, tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Walk() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWalk_IgnoreFiles(t *testing.T) {
+	root := writeWalkTree(t,
+		"main.go", "debug.log", "build/out.bin", "src/app.go", "src/gen/gen.go",
+		"src/keep.log", "src/tmp/x.go", "tmp/y.go",
+	)
+	ignores := map[string]string{
+		".gitignore":     "# comment\n*.log\nbuild/\n/tmp\n",
+		"src/.gitignore": "!keep.log\ngen/\n",
+	}
+	for name, content := range ignores {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+
+	got := walkRels(t, root, WalkOptions{IgnoreFiles: []string{".gitignore"}, Exclude: []string{".gitignore"}})
+	want := []string{"main.go", "src/app.go", "src/keep.log", "src/tmp/x.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk() = %v, want %v", got, want)
+	}
+}
+
+func TestWalk_Symlinks(t *testing.T) {
+	root := writeWalkTree(t, "dir/file.txt")
+	if err := os.Symlink("dir", filepath.Join(root, "link")); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+	if err := os.Symlink("..", filepath.Join(root, "dir", "loop")); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+
+	tests := []struct {
+		policy SymlinkPolicy
+		want   []string
+	}{
+		{SymlinkReport, []string{"dir/file.txt", "dir/loop", "link"}},
+		{SymlinkSkip, []string{"dir/file.txt"}},
+		// The loop back to the root is not followed
+		{SymlinkFollow, []string{"dir/file.txt", "link/file.txt"}},
+	}
+	for _, tt := range tests {
+		if got := walkRels(t, root, WalkOptions{Symlinks: tt.policy}); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Walk(policy %d) = %v, want %v", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestWalk_Errors(t *testing.T) {
+	root := writeWalkTree(t, "a.txt")
+
+	for _, tt := range []struct {
+		name string
+		root string
+		opts WalkOptions
+	}{
+		{"missing root", filepath.Join(root, "missing"), WalkOptions{}},
+		{"file root", filepath.Join(root, "a.txt"), WalkOptions{}},
+		{"bad pattern", root, WalkOptions{Include: []string{"["}}},
+	} {
+		var errs []error
+		for _, err := range Walk(tt.root, tt.opts) {
+			errs = append(errs, err)
+		}
+		if len(errs) != 1 || errs[0] == nil {
+			t.Errorf("Walk(%s) errors = %v, want a single error", tt.name, errs)
+		}
+	}
+
+	// Stopping early is honoured
+	count := 0
+	for range Walk(writeWalkTree(t, "a", "b", "c"), WalkOptions{}) {
+		count++
+		break
+	}
+	if count != 1 {
+		t.Errorf("Walk() went on after break, %d entries", count)
+	}
+}
+
+func TestWalkHelpers(t *testing.T) {
+	root := writeWalkTree(t, "a.go", "b.txt", "sub/c.go", "sub/d.txt", "skip/e.go")
+	if err := os.Symlink("a.go", filepath.Join(root, "link.go")); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+	opts := WalkOptions{Include: []string{"*.go"}, Exclude: []string{"skip"}}
+
+	size, err := DirSizeWithOptions(root, opts)
+	if want := int64(len("a.go") + len("sub/c.go")); err != nil || size != want {
+		t.Errorf("DirSizeWithOptions() = %d, %v, want %d", size, err, want)
+	}
+
+	dst := filepath.Join(t.TempDir(), "copy")
+	if err := CopyDirWithOptions(dst, root, opts); err != nil {
+		t.Fatalf("CopyDirWithOptions() error = %v", err)
+	}
+	if got, want := listFiles(t, dst), []string{"a.go", "link.go", "sub/c.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CopyDirWithOptions() copied %v, want %v", got, want)
+	}
+	if !IsLink(filepath.Join(dst, "link.go")) {
+		t.Error("CopyDirWithOptions() should copy links as links")
+	}
+
+	archive := filepath.Join(t.TempDir(), "out.zip")
+	if err := ZipWithOptions(archive, root, opts); err != nil {
+		t.Fatalf("ZipWithOptions() error = %v", err)
+	}
+	if got, want := zipNames(t, archive), []string{"a.go", "link.go", "sub/c.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ZipWithOptions() entries = %v, want %v", got, want)
+	}
+	extracted := t.TempDir()
+	if err := UnZip(extracted, archive); err != nil {
+		t.Fatalf("UnZip() error = %v", err)
+	}
+	if link, err := os.Readlink(filepath.Join(extracted, "link.go")); err != nil || link != "a.go" {
+		t.Errorf("zipped link = %q, %v, want a link to a.go", link, err)
+	}
+
+	if err := ZipWithOptions(archive, filepath.Join(root, "missing"), opts); err == nil {
+		t.Error("ZipWithOptions() expected error for a missing source")
+	} else if errors.Is(err, os.ErrNotExist) && IsExist(archive) {
+		t.Error("ZipWithOptions() should not leave a partial archive")
+	}
+}
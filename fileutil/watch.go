@@ -0,0 +1,131 @@
+package fileutil
+
+import (
+	"context"
+	"iter"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchOp is the set of changes of a WatchEvent
+type WatchOp uint32
+
+const (
+	WatchCreate WatchOp = 1 << iota // the path was created
+	WatchWrite                      // the file was written to
+	WatchRemove                     // the path was removed
+	WatchRename                     // the path was renamed to something else
+	WatchChmod                      // the attributes of the path changed
+)
+
+// Has tells whether op contains all of h.
+func (op WatchOp) Has(h WatchOp) bool { return op&h == h }
+
+// String returns the changes of op joined with "|", such as "CREATE|WRITE".
+func (op WatchOp) String() string {
+	var names []string
+	for _, n := range []struct {
+		op   WatchOp
+		name string
+	}{
+		{WatchCreate, "CREATE"},
+		{WatchWrite, "WRITE"},
+		{WatchRemove, "REMOVE"},
+		{WatchRename, "RENAME"},
+		{WatchChmod, "CHMOD"},
+	} {
+		if op.Has(n.op) {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// WatchEvent is a change to a path watched by Watch
+type WatchEvent struct {
+	Path string // the watched file, or the child of a watched directory, that changed
+	Op   WatchOp
+}
+
+// Watch yields the changes to paths until ctx is done. A path that is a directory when
+// Watch starts reports the changes to its direct children; any other path, existing or
+// not, reports the changes to that file.
+//
+// Files are watched through their parent directory, so that a file replaced by a
+// rename, as editors and WriteFileAtomic do, is still watched afterwards: the change
+// is yielded as WatchCreate. Errors of the watcher are yielded and the watch goes on.
+func Watch(ctx context.Context, paths ...string) iter.Seq2[WatchEvent, error] {
+	return func(yield func(WatchEvent, error) bool) {
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			yield(WatchEvent{}, err)
+			return
+		}
+		defer w.Close()
+
+		dirs := make(map[string]bool)
+		files := make(map[string]bool)
+		for _, p := range paths {
+			p = filepath.Clean(p)
+			dir := p
+			if info, err := os.Stat(p); err == nil && info.IsDir() {
+				dirs[p] = true
+			} else {
+				dir = filepath.Dir(p)
+				files[p] = true
+			}
+			if err := w.Add(dir); err != nil {
+				yield(WatchEvent{Path: p}, err)
+				return
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				name := filepath.Clean(ev.Name)
+				if !files[name] && !dirs[name] && !dirs[filepath.Dir(name)] {
+					continue
+				}
+				if op := watchOp(ev.Op); op != 0 && !yield(WatchEvent{Path: name, Op: op}, nil) {
+					return
+				}
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				if !yield(WatchEvent{}, err) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// watchOp converts the fsnotify changes to a WatchOp.
+func watchOp(op fsnotify.Op) WatchOp {
+	var res WatchOp
+	for _, m := range []struct {
+		from fsnotify.Op
+		to   WatchOp
+	}{
+		{fsnotify.Create, WatchCreate},
+		{fsnotify.Write, WatchWrite},
+		{fsnotify.Remove, WatchRemove},
+		{fsnotify.Rename, WatchRename},
+		{fsnotify.Chmod, WatchChmod},
+	} {
+		if op.Has(m.from) {
+			res |= m.to
+		}
+	}
+	return res
+}
@@ -0,0 +1,122 @@
+package fileutil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchOp(t *testing.T) {
+	op := WatchCreate | WatchWrite
+	if !op.Has(WatchWrite) || op.Has(WatchRemove) || op.Has(WatchWrite|WatchRemove) {
+		t.Errorf("%v.Has() is wrong", op)
+	}
+	if got := op.String(); got != "CREATE|WRITE" {
+		t.Errorf("String() = %q, want \"CREATE|WRITE\"", got)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.yaml")
+	other := filepath.Join(dir, "other.txt")
+	watched := filepath.Join(dir, "watched")
+	if err := os.WriteFile(config, []byte("a: 1"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := os.Mkdir(watched, 0o755); err != nil {
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan WatchEvent, 100)
+	ready := make(chan struct{})
+	go func() {
+		defer close(events)
+		close(ready)
+		for ev, err := range Watch(ctx, config, watched) {
+			if err != nil {
+				t.Errorf("Watch() error = %v", err)
+				return
+			}
+			events <- ev
+		}
+	}()
+	<-ready
+
+	// The watcher is set up in the background: write until it reports the change
+	deadline := time.Now().Add(2 * time.Second)
+	for watching := false; !watching; {
+		if time.Now().After(deadline) {
+			t.Fatal("Watch() did not start in time")
+		}
+		if err := os.WriteFile(config, []byte("a: 1"), 0o600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		select {
+		case ev := <-events:
+			watching = ev.Path == config
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// waitFor waits for an event on path with op, skipping the others
+	waitFor := func(path string, op WatchOp) {
+		t.Helper()
+		for {
+			select {
+			case ev := <-events:
+				if ev.Path == other {
+					t.Errorf("Watch() reported an unwatched file: %v", ev)
+				}
+				if ev.Path == path && ev.Op.Has(op) {
+					return
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("Watch() timed out waiting for %v on %s", op, path)
+			}
+		}
+	}
+
+	if err := os.WriteFile(other, []byte("x"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := os.WriteFile(config, []byte("a: 2"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	waitFor(config, WatchWrite)
+
+	// Replaced files are still watched
+	if err := WriteFileAtomic(config, []byte("a: 3"), 0o600); err != nil {
+		t.Fatalf("WriteFileAtomic() error = %v", err)
+	}
+	waitFor(config, WatchCreate)
+	if err := os.WriteFile(config, []byte("a: 4"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	waitFor(config, WatchWrite)
+
+	child := filepath.Join(watched, "child.txt")
+	if err := os.WriteFile(child, []byte("x"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	waitFor(child, WatchCreate)
+	if err := os.Remove(child); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	waitFor(child, WatchRemove)
+
+	cancel()
+	for range events { //nolint:revive
+	}
+
+	for _, err := range Watch(context.Background(), filepath.Join(dir, "missing", "file")) {
+		if err == nil {
+			t.Error("Watch() expected error for a missing directory")
+		}
+		break
+	}
+}
@@ -0,0 +1,352 @@
+package fileutil
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	zipEndSignature   = "PK\x05\x06"
+	zipEndLen         = 22
+	zip64LocSignature = "PK\x06\x07"
+	zip64LocLen       = 20
+	zipMaxComment     = 0xffff
+	zipMaxEntries     = 0xffff - 1     // above, zip64 records are needed
+	zipMaxOffset      = 0xffffffff - 1 // above, zip64 records are needed
+)
+
+// ZipEntryInfo describes an entry of a zip file
+type ZipEntryInfo struct {
+	Name           string      // slash separated path, ending with "/" for directories
+	Size           int64       // uncompressed size
+	CompressedSize int64       // compressed size
+	Modified       time.Time   // modification time
+	Mode           os.FileMode // permissions and type
+	IsDir          bool        // directory entry
+	CRC32          uint32      // checksum of the uncompressed content
+}
+
+// ZipList lists the entries of a zip file, in archive order.
+func ZipList(file string) ([]ZipEntryInfo, error) {
+	zipReader, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = zipReader.Close() }()
+
+	entries := make([]ZipEntryInfo, 0, len(zipReader.File))
+	for _, f := range zipReader.File {
+		entries = append(entries, ZipEntryInfo{
+			Name:           f.Name,
+			Size:           int64(min(f.UncompressedSize64, 1<<62)), //nolint:gosec // clamped
+			CompressedSize: int64(min(f.CompressedSize64, 1<<62)),   //nolint:gosec // clamped
+			Modified:       f.Modified,
+			Mode:           f.Mode(),
+			IsDir:          f.FileInfo().IsDir(),
+			CRC32:          f.CRC32,
+		})
+	}
+	return entries, nil
+}
+
+// ZipReadFile reads the content of the entry name of the zip file archive, without
+// extracting anything else. It returns an error wrapping fs.ErrNotExist if there is no
+// such entry. The content is checked against the size and checksum of the entry.
+func ZipReadFile(archive, name string) ([]byte, error) {
+	zipReader, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = zipReader.Close() }()
+
+	for _, f := range zipReader.File {
+		if f.Name != name {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = rc.Close() }()
+
+		// The zip reader fails past the declared size, so this is bounded
+		buf := bytes.NewBuffer(make([]byte, 0, min(f.UncompressedSize64, 1<<20)))
+		if _, err := io.Copy(buf, rc); err != nil { // #nosec G110
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("%s in %s: %w", name, archive, fs.ErrNotExist)
+}
+
+// ZipAppend adds the files and directories entries to the zip file dst, creating it if
+// needed. Entries are named relative to their parent directory, as with Zip.
+//
+// The new entries are written in place after the existing ones, so only the central
+// directory of the archive is rewritten. If an entry replaces an existing one, or the
+// archive needs zip64 records or has data after its central directory, the archive is
+// rewritten to a temporary file instead, copying the existing entries without
+// recompressing them, and renamed over dst.
+func ZipAppend(dst string, entries ...string) error {
+	if !IsExist(dst) {
+		return writeArchive(dst, func(w io.Writer) error {
+			archive := zip.NewWriter(w)
+			for _, entry := range entries {
+				if err := addFileToArchive(archive, entry); err != nil {
+					return err
+				}
+			}
+			return archive.Close()
+		})
+	}
+
+	names := make(map[string]bool)
+	var added int64
+	for _, entry := range entries {
+		size, err := zipEntryNames(entry, names)
+		if err != nil {
+			return err
+		}
+		added += size
+	}
+
+	f, err := os.OpenFile(dst, os.O_RDWR, 0) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	zipReader, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return err
+	}
+
+	replaces := false
+	for _, item := range zipReader.File {
+		replaces = replaces || names[item.Name]
+	}
+
+	end, appendable, err := readZipEnd(f, info.Size())
+	if err != nil {
+		return err
+	}
+	if replaces || !appendable || len(zipReader.File)+len(names) > zipMaxEntries ||
+		info.Size()+added+int64(len(names))*1024 > zipMaxOffset {
+		return rewriteZip(f, zipReader, names, entries)
+	}
+
+	return appendZipInPlace(f, end, entries)
+}
+
+// ZipAppendEntry appends a file or directory to a zip file.
+//
+// Deprecated: ZipAppendEntry writes to dst a copy of the zip file src with src added to
+// it. Use ZipAppend to add files to a zip file in place.
+func ZipAppendEntry(dst, src string) error {
+	if err := CopyFile(dst, src); err != nil {
+		return err
+	}
+	return ZipAppend(dst, src)
+}
+
+// zipEnd is the end of central directory record of a zip file
+type zipEnd struct {
+	entries   uint16
+	dirSize   uint32
+	dirOffset uint32
+	comment   string
+}
+
+// readZipEnd reads the end of central directory record of the zip file of the given
+// size. appendable is false if the archive cannot be appended to in place: zip64
+// records, multiple disks, or data between the central directory and its end record.
+func readZipEnd(r io.ReaderAt, size int64) (end *zipEnd, appendable bool, err error) {
+	tailLen := min(size, zipEndLen+zipMaxComment+zip64LocLen)
+	tail := make([]byte, tailLen)
+	if _, err := r.ReadAt(tail, size-tailLen); err != nil {
+		return nil, false, err
+	}
+
+	pos := bytes.LastIndex(tail, []byte(zipEndSignature))
+	if pos < 0 || len(tail)-pos < zipEndLen {
+		return nil, false, zip.ErrFormat
+	}
+	rec := tail[pos:]
+	le := binary.LittleEndian
+
+	end = &zipEnd{
+		entries:   le.Uint16(rec[10:]),
+		dirSize:   le.Uint32(rec[12:]),
+		dirOffset: le.Uint32(rec[16:]),
+	}
+	commentLen := int(le.Uint16(rec[20:]))
+	if zipEndLen+commentLen > len(rec) {
+		return nil, false, zip.ErrFormat
+	}
+	end.comment = string(rec[zipEndLen : zipEndLen+commentLen])
+
+	endOffset := size - tailLen + int64(pos)
+	multiDisk := le.Uint16(rec[4:]) != 0 || le.Uint16(rec[6:]) != 0 || le.Uint16(rec[8:]) != end.entries
+	zip64 := pos >= zip64LocLen && string(tail[pos-zip64LocLen:pos-zip64LocLen+4]) == zip64LocSignature
+	appendable = !multiDisk && !zip64 && int64(end.dirOffset)+int64(end.dirSize) == endOffset
+	return end, appendable, nil
+}
+
+// appendZipInPlace writes entries after the existing entries of the zip file f, then
+// writes the merged central directory. The original central directory is restored if
+// anything fails.
+func appendZipInPlace(f *os.File, end *zipEnd, entries []string) (err error) {
+	oldDirOffset := int64(end.dirOffset)
+	oldTail := make([]byte, int(end.dirSize)+zipEndLen+len(end.comment))
+	if _, err := f.ReadAt(oldTail, oldDirOffset); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_, _ = f.WriteAt(oldTail, oldDirOffset)
+			_ = f.Truncate(oldDirOffset + int64(len(oldTail)))
+		}
+	}()
+
+	// Drop the old central directory, the new entries may be shorter
+	if err := f.Truncate(oldDirOffset); err != nil {
+		return err
+	}
+	if _, err := f.Seek(oldDirOffset, io.SeekStart); err != nil {
+		return err
+	}
+	archive := zip.NewWriter(f)
+	archive.SetOffset(oldDirOffset)
+	for _, entry := range entries {
+		if err := addFileToArchive(archive, entry); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	// The writer only wrote the central directory of the new entries, read it back
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	added, appendable, err := readZipEnd(f, info.Size())
+	if err != nil {
+		return err
+	}
+	if !appendable {
+		return errors.New("zip append needs zip64 records")
+	}
+	newDirOffset := int64(added.dirOffset) // absolute, thanks to SetOffset
+	newDir := make([]byte, added.dirSize)
+	if _, err := f.ReadAt(newDir, newDirOffset); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(oldTail[:end.dirSize])
+	buf.Write(newDir)
+	writeZipEnd(&buf, &zipEnd{
+		entries:   end.entries + added.entries,
+		dirSize:   end.dirSize + added.dirSize,
+		dirOffset: added.dirOffset,
+		comment:   end.comment,
+	})
+
+	if _, err := f.WriteAt(buf.Bytes(), newDirOffset); err != nil {
+		return err
+	}
+	if err := f.Truncate(newDirOffset + int64(buf.Len())); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// writeZipEnd writes the end of central directory record end to buf.
+func writeZipEnd(buf *bytes.Buffer, end *zipEnd) {
+	le := binary.LittleEndian
+	rec := make([]byte, zipEndLen)
+	copy(rec, zipEndSignature)
+	le.PutUint16(rec[8:], end.entries)
+	le.PutUint16(rec[10:], end.entries)
+	le.PutUint32(rec[12:], end.dirSize)
+	le.PutUint32(rec[16:], end.dirOffset)
+	le.PutUint16(rec[20:], uint16(len(end.comment))) //nolint:gosec // read from a record
+	buf.Write(rec)
+	buf.WriteString(end.comment)
+}
+
+// rewriteZip rewrites the zip file f, read by zipReader, with its entries not in
+// replaced followed by entries. f is closed.
+func rewriteZip(f *os.File, zipReader *zip.Reader, replaced map[string]bool, entries []string) error {
+	dst := f.Name()
+	tempFile, err := os.CreateTemp(filepath.Dir(dst), ".ziwi-zip-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+
+	archive := zip.NewWriter(tempFile)
+	if err := archive.SetComment(zipReader.Comment); err != nil {
+		return err
+	}
+	for _, item := range zipReader.File {
+		if replaced[item.Name] {
+			continue
+		}
+		// Copies the compressed data as is
+		if err := archive.Copy(item); err != nil {
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if err := addFileToArchive(archive, entry); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+
+	if info, err := f.Stat(); err == nil {
+		_ = os.Chmod(tempFile.Name(), info.Mode().Perm())
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), dst)
+}
+
+// zipEntryNames adds to names the names addFileToArchive gives to the files of src,
+// and returns their total size.
+func zipEntryNames(src string, names map[string]bool) (int64, error) {
+	var size int64
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		names[strings.TrimPrefix(path, filepath.Dir(src)+"/")] = true
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,156 @@
+package fileutil
+
+import (
+	"archive/zip"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func zipNames(t *testing.T, file string) []string {
+	t.Helper()
+
+	entries, err := ZipList(file)
+	if err != nil {
+		t.Fatalf("ZipList() error = %v", err)
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name
+	}
+	return names
+}
+
+func TestZipListAndReadFile(t *testing.T) {
+	src := writeTestZip(t, []testZipEntry{
+		{name: "dir/", mode: os.ModeDir | 0o755},
+		{name: "dir/a.txt", content: "alpha", mode: 0o600},
+		{name: "b.txt", content: "bravo"},
+	})
+
+	entries, err := ZipList(src)
+	if err != nil {
+		t.Fatalf("ZipList() error = %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("ZipList() got %d entries, want 3", len(entries))
+	}
+	if e := entries[0]; e.Name != "dir/" || !e.IsDir {
+		t.Errorf("ZipList() entry 0 = %+v, want directory dir/", e)
+	}
+	if e := entries[1]; e.Name != "dir/a.txt" || e.IsDir || e.Size != 5 || e.Mode.Perm() != 0o600 ||
+		!e.Modified.Equal(testZipTime) || e.CRC32 == 0 {
+		t.Errorf("ZipList() entry 1 = %+v", e)
+	}
+
+	content, err := ZipReadFile(src, "dir/a.txt")
+	if err != nil || string(content) != "alpha" {
+		t.Errorf("ZipReadFile() = %q, %v, want \"alpha\"", content, err)
+	}
+	if _, err := ZipReadFile(src, "missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ZipReadFile() error = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := ZipList("non_existent.zip"); err == nil {
+		t.Error("ZipList() expected error for a missing file")
+	}
+}
+
+func TestZipAppend(t *testing.T) {
+	tree := writeTestTree(t)
+	dst := writeTestZip(t, []testZipEntry{{name: "first.txt", content: "first"}})
+
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	before, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	if err := ZipAppend(dst, filepath.Join(tree, "a.txt"), filepath.Join(tree, "sub")); err != nil {
+		t.Fatalf("ZipAppend() error = %v", err)
+	}
+
+	want := []string{"first.txt", "a.txt", "sub/b.txt", "sub/deep/c.go"}
+	if got := zipNames(t, dst); !reflect.DeepEqual(got, want) {
+		t.Errorf("ZipAppend() entries = %v, want %v", got, want)
+	}
+
+	// The existing entries were left in place
+	after, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if end, _, err := readZipEnd(bytesReaderAt(before), info.Size()); err != nil ||
+		string(after[:end.dirOffset]) != string(before[:end.dirOffset]) {
+		t.Error("ZipAppend() rewrote the existing entries")
+	}
+
+	for name, content := range map[string]string{"first.txt": "first", "sub/deep/c.go": "package c"} {
+		if got, err := ZipReadFile(dst, name); err != nil || string(got) != content {
+			t.Errorf("ZipReadFile(%s) = %q, %v, want %q", name, got, err, content)
+		}
+	}
+
+	// Appending again keeps the archive valid
+	if err := ZipAppend(dst, filepath.Join(tree, "sub", "deep")); err != nil {
+		t.Fatalf("ZipAppend() error = %v", err)
+	}
+	if got := zipNames(t, dst); len(got) != 5 || got[4] != "deep/c.go" {
+		t.Errorf("ZipAppend() entries = %v", got)
+	}
+}
+
+func TestZipAppend_Rewrite(t *testing.T) {
+	tree := writeTestTree(t)
+	dst := writeTestZip(t, []testZipEntry{
+		{name: "a.txt", content: "old"},
+		{name: "keep.txt", content: "keep"},
+	})
+
+	// a.txt replaces an existing entry, so the archive is rewritten
+	if err := ZipAppend(dst, filepath.Join(tree, "a.txt")); err != nil {
+		t.Fatalf("ZipAppend() error = %v", err)
+	}
+	if got, want := zipNames(t, dst), []string{"keep.txt", "a.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ZipAppend() entries = %v, want %v", got, want)
+	}
+	if got, err := ZipReadFile(dst, "a.txt"); err != nil || string(got) != "alpha" {
+		t.Errorf("ZipReadFile(a.txt) = %q, %v, want \"alpha\"", got, err)
+	}
+	if got, err := ZipReadFile(dst, "keep.txt"); err != nil || string(got) != "keep" {
+		t.Errorf("ZipReadFile(keep.txt) = %q, %v, want \"keep\"", got, err)
+	}
+}
+
+func TestZipAppend_Create(t *testing.T) {
+	tree := writeTestTree(t)
+	dst := filepath.Join(t.TempDir(), "new.zip")
+
+	if err := ZipAppend(dst, filepath.Join(tree, "sub")); err != nil {
+		t.Fatalf("ZipAppend() error = %v", err)
+	}
+	if got, want := zipNames(t, dst), []string{"sub/b.txt", "sub/deep/c.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ZipAppend() entries = %v, want %v", got, want)
+	}
+
+	if err := ZipAppend(dst, filepath.Join(tree, "missing")); err == nil {
+		t.Error("ZipAppend() expected error for a missing entry")
+	}
+	if r, err := zip.OpenReader(dst); err != nil {
+		t.Errorf("ZipAppend() failure corrupted the archive: %v", err)
+	} else {
+		_ = r.Close()
+	}
+}
+
+// bytesReaderAt reads from a byte slice
+type bytesReaderAt []byte
+
+func (b bytesReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	return copy(p, b[off:]), nil
+}
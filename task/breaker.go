@@ -0,0 +1,353 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kydance/ziwi/log"
+)
+
+// ErrCircuitOpen is returned instead of calling the task while a CircuitBreaker is open
+var ErrCircuitOpen = errors.New("task: circuit breaker open")
+
+// BreakerState is the state of a CircuitBreaker
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // calls pass through, failures are counted
+	BreakerOpen                         // calls fail fast with ErrCircuitOpen
+	BreakerHalfOpen                     // a few probe calls decide whether to close again
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("BreakerState(%d)", int(s))
+	}
+}
+
+// BreakerCounts are the calls recorded in the rolling window of a CircuitBreaker
+type BreakerCounts struct {
+	Requests uint64
+	Failures uint64
+}
+
+// BreakerOption defines the functional option type for CircuitBreaker configuration
+type BreakerOption func(*CircuitBreaker)
+
+// WithFailureRatio configures the ratio of failed calls in the rolling window that opens
+// the breaker, 0.5 by default
+func WithFailureRatio(ratio float64) BreakerOption {
+	return func(cb *CircuitBreaker) { cb.failureRatio = ratio }
+}
+
+// WithMinRequests configures how many calls the rolling window must hold before the
+// failure ratio is considered, 10 by default
+func WithMinRequests(n uint64) BreakerOption {
+	return func(cb *CircuitBreaker) { cb.minRequests = n }
+}
+
+// WithRollingWindow configures the rolling window over which failures are counted and
+// the number of buckets it is divided into, 10 seconds in 10 buckets by default
+func WithRollingWindow(window time.Duration, buckets int) BreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.window = window
+		cb.buckets = make([]BreakerCounts, max(buckets, 1))
+	}
+}
+
+// WithCooldown configures how long the breaker stays open before probing, 5 seconds by
+// default
+func WithCooldown(d time.Duration) BreakerOption {
+	return func(cb *CircuitBreaker) { cb.cooldown = d }
+}
+
+// WithHalfOpenProbes configures how many calls are let through while half-open; all of
+// them must succeed to close the breaker. 1 by default.
+func WithHalfOpenProbes(n int) BreakerOption {
+	return func(cb *CircuitBreaker) { cb.probes = n }
+}
+
+// WithFailurePredicate configures which errors count as failures. By default every
+// error but context.Canceled does.
+func WithFailurePredicate(isFailure func(err error) bool) BreakerOption {
+	return func(cb *CircuitBreaker) { cb.isFailure = isFailure }
+}
+
+// WithStateChange configures a callback invoked after every state transition, see
+// LogStateChange
+func WithStateChange(fn func(name string, from, to BreakerState)) BreakerOption {
+	return func(cb *CircuitBreaker) { cb.onStateChange = fn }
+}
+
+// WithBreakerClock configures the clock of the breaker, tests use a ManualClock
+func WithBreakerClock(clock Clock) BreakerOption {
+	return func(cb *CircuitBreaker) { cb.clock = clock }
+}
+
+// LogStateChange logs a state transition through the global ziwi logger. Use it with
+// WithStateChange.
+func LogStateChange(name string, from, to BreakerState) {
+	if to == BreakerOpen {
+		log.Warnw("Circuit breaker opened", "breaker", name, "from", from.String())
+		return
+	}
+	log.Infow("Circuit breaker state changed", "breaker", name, "from", from.String(), "to", to.String())
+}
+
+// CircuitBreaker stops calling a failing downstream for a while, so that it can recover
+// instead of being hammered by retries.
+//
+// While closed, calls are counted in a rolling window and the breaker opens once the
+// failure ratio reaches the threshold. While open, calls fail fast with ErrCircuitOpen.
+// After the cooldown the breaker becomes half-open and lets a few probe calls through:
+// if they all succeed it closes, otherwise it opens again.
+type CircuitBreaker struct {
+	name          string
+	failureRatio  float64
+	minRequests   uint64
+	window        time.Duration
+	cooldown      time.Duration
+	probes        int
+	isFailure     func(err error) bool
+	onStateChange func(name string, from, to BreakerState)
+	clock         Clock
+
+	mu         sync.Mutex
+	state      BreakerState
+	generation uint64          // incremented on every transition, stale results are ignored
+	buckets    []BreakerCounts // ring of window buckets
+	bucketAt   time.Time       // start of the current bucket
+	current    int             // index of the current bucket
+	openedAt   time.Time
+	inFlight   int           // probes in progress while half-open
+	succeeded  int           // successful probes while half-open
+	changes    []stateChange // transitions not reported to onStateChange yet
+}
+
+type stateChange struct{ from, to BreakerState }
+
+// NewCircuitBreaker creates a new closed CircuitBreaker
+func NewCircuitBreaker(name string, options ...BreakerOption) *CircuitBreaker {
+	cb := &CircuitBreaker{
+		name:         name,
+		failureRatio: 0.5,
+		minRequests:  10,
+		window:       10 * time.Second,
+		buckets:      make([]BreakerCounts, 10),
+		cooldown:     5 * time.Second,
+		probes:       1,
+		isFailure:    func(err error) bool { return !errors.Is(err, context.Canceled) },
+		clock:        SystemClock,
+	}
+	for _, option := range options {
+		option(cb)
+	}
+	if cb.probes < 1 {
+		cb.probes = 1
+	}
+	cb.bucketAt = cb.clock.Now()
+	return cb
+}
+
+// Name returns the name of the breaker
+func (cb *CircuitBreaker) Name() string { return cb.name }
+
+// State returns the current state of the breaker.
+func (cb *CircuitBreaker) State() BreakerState {
+	cb.mu.Lock()
+	state := cb.advance(cb.clock.Now())
+	changes := cb.takeChanges()
+	cb.mu.Unlock()
+
+	cb.notify(changes)
+	return state
+}
+
+// Counts returns the calls recorded in the rolling window.
+func (cb *CircuitBreaker) Counts() BreakerCounts {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.rotate(cb.clock.Now())
+	return cb.totals()
+}
+
+// Reset closes the breaker and clears its counts.
+func (cb *CircuitBreaker) Reset() {
+	cb.mu.Lock()
+	cb.transition(BreakerClosed, cb.clock.Now())
+	changes := cb.takeChanges()
+	cb.mu.Unlock()
+
+	cb.notify(changes)
+}
+
+// Allow reports whether a call may proceed. If it may, the caller must invoke done with
+// the outcome of the call; otherwise the error is ErrCircuitOpen.
+func (cb *CircuitBreaker) Allow() (done func(err error), err error) {
+	cb.mu.Lock()
+	switch state := cb.advance(cb.clock.Now()); {
+	case state == BreakerOpen:
+		err = ErrCircuitOpen
+	case state == BreakerHalfOpen && cb.inFlight+cb.succeeded >= cb.probes:
+		err = ErrCircuitOpen // enough probes are already in progress
+	case state == BreakerHalfOpen:
+		cb.inFlight++
+	}
+	generation := cb.generation
+	changes := cb.takeChanges()
+	cb.mu.Unlock()
+
+	cb.notify(changes)
+	if err != nil {
+		return nil, err
+	}
+	return func(err error) { cb.record(generation, err) }, nil
+}
+
+// Execute calls fn unless the breaker is open, and records its outcome.
+func (cb *CircuitBreaker) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	done, err := cb.Allow()
+	if err != nil {
+		return err
+	}
+
+	_, err = safeCall(ctx, func(ctx context.Context) (struct{}, error) { return struct{}{}, fn(ctx) })
+	done(err)
+	return err
+}
+
+// Wrap returns a TaskFunc that calls fn through the breaker.
+func (cb *CircuitBreaker) Wrap(fn TaskFunc) TaskFunc {
+	return func(ctx context.Context, chunk []any) (any, error) {
+		done, err := cb.Allow()
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := safeCall(ctx, func(ctx context.Context) (any, error) { return fn(ctx, chunk) })
+		done(err)
+		return result, err
+	}
+}
+
+// record accounts the outcome of a call allowed in the given generation.
+func (cb *CircuitBreaker) record(generation uint64, err error) {
+	failed := err != nil && cb.isFailure(err)
+
+	cb.mu.Lock()
+	now := cb.clock.Now()
+	state := cb.advance(now)
+
+	// An outcome from before the last state change is stale and ignored
+	if generation == cb.generation {
+		cb.recordLocked(state, failed, now)
+	}
+	changes := cb.takeChanges()
+	cb.mu.Unlock()
+
+	cb.notify(changes)
+}
+
+// recordLocked accounts a call outcome in the current state. Locked.
+func (cb *CircuitBreaker) recordLocked(state BreakerState, failed bool, now time.Time) {
+	switch state {
+	case BreakerClosed:
+		cb.buckets[cb.current].Requests++
+		if failed {
+			cb.buckets[cb.current].Failures++
+		}
+		if counts := cb.totals(); counts.Requests >= cb.minRequests &&
+			float64(counts.Failures) >= cb.failureRatio*float64(counts.Requests) {
+			cb.transition(BreakerOpen, now)
+		}
+	case BreakerHalfOpen:
+		cb.inFlight--
+		if failed {
+			cb.transition(BreakerOpen, now)
+		} else if cb.succeeded++; cb.succeeded >= cb.probes {
+			cb.transition(BreakerClosed, now)
+		}
+	}
+}
+
+// advance moves an open breaker to half-open once the cooldown has elapsed and returns
+// the current state. Locked.
+func (cb *CircuitBreaker) advance(now time.Time) BreakerState {
+	if cb.state == BreakerOpen && !now.Before(cb.openedAt.Add(cb.cooldown)) {
+		cb.transition(BreakerHalfOpen, now)
+	}
+	cb.rotate(now)
+	return cb.state
+}
+
+// transition switches to state and resets the counters of the new state. Locked.
+func (cb *CircuitBreaker) transition(state BreakerState, now time.Time) {
+	if cb.state != state {
+		cb.changes = append(cb.changes, stateChange{from: cb.state, to: state})
+	}
+	cb.state = state
+	cb.generation++
+	cb.inFlight, cb.succeeded = 0, 0
+	clear(cb.buckets)
+	cb.bucketAt, cb.current = now, 0
+	if state == BreakerOpen {
+		cb.openedAt = now
+	}
+}
+
+// rotate drops the buckets that fell out of the rolling window. Locked.
+func (cb *CircuitBreaker) rotate(now time.Time) {
+	width := cb.window / time.Duration(len(cb.buckets))
+	if width <= 0 {
+		return
+	}
+
+	elapsed := int(now.Sub(cb.bucketAt) / width)
+	if elapsed <= 0 {
+		return
+	}
+	for i := range min(elapsed, len(cb.buckets)) {
+		cb.buckets[(cb.current+1+i)%len(cb.buckets)] = BreakerCounts{}
+	}
+	cb.current = (cb.current + elapsed) % len(cb.buckets)
+	cb.bucketAt = cb.bucketAt.Add(time.Duration(elapsed) * width)
+}
+
+// totals sums the buckets of the rolling window. Locked.
+func (cb *CircuitBreaker) totals() BreakerCounts {
+	var counts BreakerCounts
+	for _, b := range cb.buckets {
+		counts.Requests += b.Requests
+		counts.Failures += b.Failures
+	}
+	return counts
+}
+
+// takeChanges returns and clears the pending state changes. Locked.
+func (cb *CircuitBreaker) takeChanges() []stateChange {
+	changes := cb.changes
+	cb.changes = nil
+	return changes
+}
+
+// notify invokes the state change callback for each change, outside the lock so the
+// callback may use the breaker.
+func (cb *CircuitBreaker) notify(changes []stateChange) {
+	if cb.onStateChange == nil {
+		return
+	}
+	for _, c := range changes {
+		cb.onStateChange(cb.name, c.from, c.to)
+	}
+}
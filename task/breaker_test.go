@@ -0,0 +1,174 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errDown = errors.New("downstream down")
+
+func failing(context.Context) error { return errDown }
+
+func succeeding(context.Context) error { return nil }
+
+func TestCircuitBreaker_Transitions(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	var (
+		mu          sync.Mutex
+		transitions []string
+	)
+	cb := NewCircuitBreaker("downstream",
+		WithBreakerClock(clock),
+		WithMinRequests(4),
+		WithFailureRatio(0.5),
+		WithCooldown(time.Second),
+		WithStateChange(func(name string, from, to BreakerState) {
+			LogStateChange(name, from, to)
+			mu.Lock()
+			transitions = append(transitions, from.String()+"->"+to.String())
+			mu.Unlock()
+		}),
+	)
+
+	// Below the minimum number of requests the breaker stays closed
+	for range 3 {
+		_ = cb.Execute(context.Background(), failing)
+	}
+	if cb.State() != BreakerClosed {
+		t.Fatalf("Expected closed below the minimum requests, got %v", cb.State())
+	}
+	_ = cb.Execute(context.Background(), failing)
+	if cb.State() != BreakerOpen {
+		t.Fatalf("Expected open after 4 failures, got %v", cb.State())
+	}
+
+	// Open: calls fail fast
+	called := false
+	err := cb.Execute(context.Background(), func(context.Context) error { called = true; return nil })
+	if !errors.Is(err, ErrCircuitOpen) || called {
+		t.Errorf("Expected ErrCircuitOpen without calling the task, got %v (called %v)", err, called)
+	}
+
+	// A failed probe opens the breaker again, a successful one closes it
+	clock.Advance(time.Second)
+	_ = cb.Execute(context.Background(), failing)
+	if cb.State() != BreakerOpen {
+		t.Fatalf("Expected open after a failed probe, got %v", cb.State())
+	}
+	clock.Advance(time.Second)
+	if err := cb.Execute(context.Background(), succeeding); err != nil {
+		t.Fatalf("Probe failed: %v", err)
+	}
+	if cb.State() != BreakerClosed {
+		t.Fatalf("Expected closed after a successful probe, got %v", cb.State())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}
+	if len(transitions) != len(want) {
+		t.Fatalf("Expected transitions %v, got %v", want, transitions)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Errorf("Transition %d: expected %s, got %s", i, want[i], transitions[i])
+		}
+	}
+}
+
+func TestCircuitBreaker_RollingWindow(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	cb := NewCircuitBreaker("downstream",
+		WithBreakerClock(clock),
+		WithMinRequests(4),
+		WithRollingWindow(4*time.Second, 4),
+	)
+
+	_ = cb.Execute(context.Background(), failing)
+	_ = cb.Execute(context.Background(), failing)
+	clock.Advance(3 * time.Second)
+	_ = cb.Execute(context.Background(), succeeding)
+	if c := cb.Counts(); c.Requests != 3 || c.Failures != 2 {
+		t.Errorf("Expected 3 requests and 2 failures in the window, got %+v", c)
+	}
+
+	// The failures fall out of the window
+	clock.Advance(2 * time.Second)
+	_ = cb.Execute(context.Background(), failing)
+	_ = cb.Execute(context.Background(), succeeding)
+	_ = cb.Execute(context.Background(), succeeding)
+	if c := cb.Counts(); c.Requests != 4 || c.Failures != 1 {
+		t.Errorf("Expected 4 requests and 1 failure in the window, got %+v", c)
+	}
+	if cb.State() != BreakerClosed {
+		t.Errorf("Expected closed with a 25%% failure ratio, got %v", cb.State())
+	}
+}
+
+func TestCircuitBreaker_HalfOpenProbes(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	cb := NewCircuitBreaker("downstream",
+		WithBreakerClock(clock),
+		WithMinRequests(1),
+		WithCooldown(time.Second),
+		WithHalfOpenProbes(2),
+	)
+	_ = cb.Execute(context.Background(), failing)
+	clock.Advance(time.Second)
+
+	done1, err1 := cb.Allow()
+	done2, err2 := cb.Allow()
+	if _, err := cb.Allow(); err1 != nil || err2 != nil || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected exactly 2 probes to be allowed, got %v, %v, %v", err1, err2, err)
+	}
+	done1(nil)
+	if cb.State() != BreakerHalfOpen {
+		t.Errorf("Expected half-open until every probe succeeded, got %v", cb.State())
+	}
+	done2(nil)
+	if cb.State() != BreakerClosed {
+		t.Errorf("Expected closed after both probes succeeded, got %v", cb.State())
+	}
+}
+
+func TestCircuitBreaker_Canceled(t *testing.T) {
+	cb := NewCircuitBreaker("downstream", WithMinRequests(1))
+	_ = cb.Execute(context.Background(), func(context.Context) error { return context.Canceled })
+	if cb.State() != BreakerClosed {
+		t.Errorf("Expected context.Canceled not to count as a failure, got %v", cb.State())
+	}
+}
+
+func TestTaskProcessor_CircuitBreaker(t *testing.T) {
+	cb := NewCircuitBreaker("downstream", WithMinRequests(2), WithCooldown(time.Hour))
+	var (
+		calls    atomic.Int32
+		openErrs atomic.Int32
+	)
+	processor := NewTaskProcessor(
+		WithMaxWorkerCount(1),
+		WithChunkStrategy(FixedChunkSize(1)),
+		WithCircuitBreaker(cb),
+		WithErrorHandler(func(err error) {
+			if errors.Is(err, ErrCircuitOpen) {
+				openErrs.Add(1)
+			}
+		}),
+	)
+
+	err := processor.ProcessInChunks(context.Background(), make([]any, 10),
+		func(context.Context, []any) (any, error) {
+			calls.Add(1)
+			return nil, errDown
+		})
+	if err != nil {
+		t.Fatalf("ProcessInChunks failed: %v", err)
+	}
+	if calls.Load() != 2 || openErrs.Load() != 8 {
+		t.Errorf("Expected 2 calls and 8 fast failures, got %d and %d", calls.Load(), openErrs.Load())
+	}
+}
@@ -0,0 +1,107 @@
+package task
+
+// ChunkStrategy decides how ProcessInChunks splits tasks into chunks
+type ChunkStrategy interface {
+	// Split partitions tasks into contiguous chunks for the given number of workers.
+	Split(tasks []any, workers int) [][]any
+}
+
+// ChunkStrategyFunc adapts an ordinary function to the ChunkStrategy interface
+type ChunkStrategyFunc func(tasks []any, workers int) [][]any
+
+// Split implements ChunkStrategy
+func (f ChunkStrategyFunc) Split(tasks []any, workers int) [][]any { return f(tasks, workers) }
+
+// FixedChunkSize splits tasks into chunks of size tasks each; the last chunk may be smaller.
+func FixedChunkSize(size int) ChunkStrategy {
+	return ChunkStrategyFunc(func(tasks []any, _ int) [][]any {
+		return splitBySize(tasks, size)
+	})
+}
+
+// FixedChunkCount splits tasks into n chunks of nearly equal size.
+// If n is not positive, one chunk per worker is used, which is the default strategy.
+func FixedChunkCount(n int) ChunkStrategy {
+	return ChunkStrategyFunc(func(tasks []any, workers int) [][]any {
+		count := n
+		if count <= 0 {
+			count = workers
+		}
+		if count <= 0 {
+			count = 1
+		}
+		return splitBySize(tasks, (len(tasks)+count-1)/count) // ceiling division
+	})
+}
+
+// WeightedChunks splits tasks into one chunk per worker so that each chunk has roughly
+// the same total cost. cost returns the relative cost of a single task.
+func WeightedChunks(cost func(task any) float64) ChunkStrategy {
+	return ChunkStrategyFunc(func(tasks []any, workers int) [][]any {
+		if workers <= 0 {
+			workers = 1
+		}
+
+		costs := make([]float64, len(tasks))
+		var total float64
+		for i, task := range tasks {
+			costs[i] = max(cost(task), 0)
+			total += costs[i]
+		}
+
+		chunks := make([][]any, 0, workers)
+		var (
+			begin int
+			sum   float64
+		)
+		for i := range tasks {
+			sum += costs[i]
+
+			// Cut once this chunk reaches its share of the total cost, keeping one
+			// task for each of the remaining chunks
+			remaining := workers - len(chunks) - 1
+			share := total * float64(len(chunks)+1) / float64(workers)
+			if remaining > 0 && sum >= share && len(tasks)-i-1 >= remaining {
+				chunks = append(chunks, tasks[begin:i+1])
+				begin = i + 1
+			}
+		}
+		if begin < len(tasks) {
+			chunks = append(chunks, tasks[begin:])
+		}
+		return chunks
+	})
+}
+
+// DynamicChunks makes ProcessInChunks start one long-lived goroutine per worker that
+// repeatedly pulls the next chunk of size tasks from a shared queue until it is empty.
+// Fast workers keep taking chunks while slow ones are busy, so a few expensive tasks
+// no longer stall the whole batch.
+func DynamicChunks(size int) ChunkStrategy {
+	if size <= 0 {
+		size = 1
+	}
+	return dynamicChunks{size: size}
+}
+
+type dynamicChunks struct{ size int }
+
+// Split implements ChunkStrategy
+func (d dynamicChunks) Split(tasks []any, _ int) [][]any { return splitBySize(tasks, d.size) }
+
+// splitBySize splits tasks into chunks of at most size tasks.
+func splitBySize(tasks []any, size int) [][]any {
+	if size <= 0 {
+		size = 1
+	}
+
+	chunks := make([][]any, 0, (len(tasks)+size-1)/size)
+	for i := 0; i < len(tasks); i += size {
+		end := i + size
+		if end > len(tasks) {
+			end = len(tasks)
+		}
+		chunks = append(chunks, tasks[i:end])
+	}
+	return chunks
+}
@@ -0,0 +1,128 @@
+package task
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func chunkSizes(chunks [][]any) []int {
+	sizes := make([]int, len(chunks))
+	for i, chunk := range chunks {
+		sizes[i] = len(chunk)
+	}
+	return sizes
+}
+
+func TestChunkStrategies(t *testing.T) {
+	tasks := make([]any, 10)
+	for i := range tasks {
+		tasks[i] = i
+	}
+
+	tests := []struct {
+		name     string
+		strategy ChunkStrategy
+		workers  int
+		want     []int
+	}{
+		{"fixed size", FixedChunkSize(4), 2, []int{4, 4, 2}},
+		{"fixed count", FixedChunkCount(5), 2, []int{2, 2, 2, 2, 2}},
+		{"default count", FixedChunkCount(0), 3, []int{4, 4, 2}},
+		{"weighted", WeightedChunks(func(task any) float64 {
+			if task.(int) == 0 {
+				return 9 // the first task costs as much as all the others
+			}
+			return 1
+		}), 2, []int{1, 9}},
+		{"dynamic", DynamicChunks(3), 2, []int{3, 3, 3, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := chunkSizes(tt.strategy.Split(tasks, tt.workers))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected chunk sizes %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFixedChunkCount_Concurrent(t *testing.T) {
+	tasks := make([]any, 20)
+	strategy := FixedChunkCount(0)
+
+	// The default count follows the workers of every call, concurrent or not
+	var wg sync.WaitGroup
+	for _, workers := range []int{2, 10, 2, 10, 4, 5} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := len(strategy.Split(tasks, workers)); got != workers {
+				t.Errorf("Expected %d chunks for %d workers, got %d", workers, workers, got)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTaskProcessor_DynamicChunks(t *testing.T) {
+	processor := NewTaskProcessor(
+		WithMaxWorkerCount(4),
+		WithChunkStrategy(DynamicChunks(1)),
+	)
+
+	// One slow task must not hold back the others
+	tasks := make([]any, 100)
+	tasks[0] = 50 * time.Millisecond
+
+	var processed atomic.Int64
+	err := processor.ProcessInChunks(context.Background(), tasks,
+		func(_ context.Context, chunk []any) (any, error) {
+			for _, task := range chunk {
+				if d, ok := task.(time.Duration); ok {
+					time.Sleep(d)
+				}
+				processed.Add(1)
+			}
+			return nil, nil
+		})
+	if err != nil {
+		t.Fatalf("ProcessInChunks failed: %v", err)
+	}
+
+	if n := processed.Load(); n != 100 {
+		t.Errorf("Expected 100 processed tasks, got %d", n)
+	}
+	stats := processor.Metrics()
+	if stats.CompletedTasks != 100 || stats.QueueDepth != 0 {
+		t.Errorf("Expected 100 completed chunks and an empty queue, got %+v", stats)
+	}
+}
+
+func TestTaskProcessor_DynamicChunksCancel(t *testing.T) {
+	processor := NewTaskProcessor(
+		WithMaxWorkerCount(2),
+		WithChunkStrategy(DynamicChunks(1)),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int64
+	_ = processor.ProcessInChunks(ctx, make([]any, 100),
+		func(_ context.Context, _ []any) (any, error) {
+			if calls.Add(1) == 10 {
+				cancel()
+			}
+			return nil, nil
+		})
+
+	if n := calls.Load(); n >= 100 {
+		t.Errorf("Expected cancellation to stop workers early, got %d calls", n)
+	}
+	if depth := processor.Metrics().QueueDepth; depth != 0 {
+		t.Errorf("Expected unclaimed chunks to leave the queue, got depth %d", depth)
+	}
+}
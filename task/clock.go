@@ -0,0 +1,133 @@
+package task
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for schedulers and circuit breakers.
+// Tests inject a ManualClock to advance time deterministically.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTimer creates a Timer that fires once d has elapsed.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single-shot timer created by a Clock
+type Timer interface {
+	// C returns the channel on which the fire time is delivered.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing. It reports whether the timer was stopped
+	// before it fired.
+	Stop() bool
+}
+
+// SystemClock is the Clock backed by the time package
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTimer(d time.Duration) Timer { return systemTimer{time.NewTimer(d)} }
+
+type systemTimer struct{ t *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time { return t.t.C }
+
+func (t systemTimer) Stop() bool { return t.t.Stop() }
+
+// ManualClock is a Clock whose time only moves when Advance or Set is called
+type ManualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*manualTimer // pending timers
+}
+
+// NewManualClock creates a ManualClock set to now
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now implements Clock
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// NewTimer implements Clock
+func (c *ManualClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &manualTimer{clock: c, when: c.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.ch <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d and fires every timer that became due.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	now := c.now.Add(d)
+	c.mu.Unlock()
+
+	c.Set(now)
+}
+
+// Set moves the clock to now and fires every timer that became due, earliest first.
+func (c *ManualClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = now
+	sort.Slice(c.timers, func(i, j int) bool { return c.timers[i].when.Before(c.timers[j].when) })
+
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.when.After(now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.ch <- now
+	}
+	c.timers = pending
+}
+
+// PendingTimers returns the number of timers waiting to fire. Tests use it to wait
+// until a goroutine has armed its timer before advancing the clock.
+func (c *ManualClock) PendingTimers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+type manualTimer struct {
+	clock *ManualClock
+	when  time.Time
+	ch    chan time.Time
+}
+
+func (t *manualTimer) C() <-chan time.Time { return t.ch }
+
+func (t *manualTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
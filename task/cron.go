@@ -0,0 +1,222 @@
+package task
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes the fire times of a periodic job
+type Schedule interface {
+	// Next returns the first fire time strictly after t.
+	Next(t time.Time) time.Time
+}
+
+// Every returns a Schedule firing at a fixed interval
+func Every(d time.Duration) Schedule {
+	if d <= 0 {
+		d = time.Second
+	}
+	return intervalSchedule(d)
+}
+
+type intervalSchedule time.Duration
+
+func (s intervalSchedule) Next(t time.Time) time.Time { return t.Add(time.Duration(s)) }
+
+// cronField describes the bounds and names of a cron field
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronMinute = cronField{name: "minute", min: 0, max: 59}
+	cronHour   = cronField{name: "hour", min: 0, max: 23}
+	cronDom    = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronDow = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSchedule is a parsed cron expression; every field is a bit set of allowed values
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+
+	domAny, dowAny bool // field was "*", used for the day matching rule
+	loc            *time.Location
+}
+
+// ParseCron parses a standard five-field cron expression
+// ("minute hour day-of-month month day-of-week"), evaluated in the local time zone.
+//
+// Fields accept "*", values, ranges ("1-5"), steps ("*/15", "0-30/10") and lists
+// ("1,15,30"); months and weekdays also accept three-letter names ("jan", "mon").
+// The descriptors @yearly, @monthly, @weekly, @daily, @hourly and "@every <duration>"
+// are supported too. As in classic cron, when both day fields are restricted a day
+// matches if either of them does.
+func ParseCron(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if rest, ok := strings.CutPrefix(expr, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("cron: invalid interval %q", rest)
+		}
+		return Every(d), nil
+	}
+	if spec, ok := cronDescriptors[strings.ToLower(expr)]; ok {
+		expr = spec
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron: expected 5 fields, got %d in %q", len(fields), expr)
+	}
+
+	s := &cronSchedule{loc: time.Local}
+	var err error
+	if s.minute, err = cronMinute.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if s.hour, err = cronHour.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	if s.dom, err = cronDom.parse(fields[2]); err != nil {
+		return nil, err
+	}
+	if s.month, err = cronMonth.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	if s.dow, err = cronDow.parse(fields[4]); err != nil {
+		return nil, err
+	}
+
+	// 7 is an alias of Sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	// As in Vixie cron, a day field starting with "*", such as "*/2", is unrestricted
+	s.domAny = strings.HasPrefix(fields[2], "*") || fields[2] == "?"
+	s.dowAny = strings.HasPrefix(fields[4], "*") || fields[4] == "?"
+	return s, nil
+}
+
+// MustParseCron is like ParseCron but panics if the expression cannot be parsed.
+func MustParseCron(expr string) Schedule {
+	s, err := ParseCron(expr)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// parse converts a comma separated list of field terms into a bit set.
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+	for _, term := range strings.Split(field, ",") {
+		lo, hi, step := f.min, f.max, 1
+
+		rangePart, stepPart, hasStep := strings.Cut(term, "/")
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("cron: invalid step %q in %s field", stepPart, f.name)
+			}
+			step = n
+		}
+
+		if rangePart != "*" && rangePart != "?" {
+			first, last, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = f.value(first); err != nil {
+				return 0, err
+			}
+			switch {
+			case isRange:
+				if hi, err = f.value(last); err != nil {
+					return 0, err
+				}
+			case !hasStep:
+				hi = lo
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("cron: invalid range %q in %s field", rangePart, f.name)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// value parses a single number or name of the field.
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("cron: invalid value %q in %s field", s, f.name)
+	}
+	return v, nil
+}
+
+// Next implements Schedule
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	origLoc := t.Location()
+	t = t.In(s.loc).Truncate(time.Minute).Add(time.Minute)
+
+	// Any valid expression fires within a few years, give up after that
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Truncate(time.Minute).Add(time.Minute)
+			continue
+		}
+		return t.In(origLoc)
+	}
+	return time.Time{}
+}
+
+// dayMatches applies the cron day rule: if both day fields are restricted, either one
+// matching is enough.
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domAny || s.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,65 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron_Next(t *testing.T) {
+	from := time.Date(2024, time.January, 31, 10, 17, 30, 0, time.Local) // a Wednesday
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, time.January, 31, 10, 18, 0, 0, time.Local)},
+		{"*/15 * * * *", time.Date(2024, time.January, 31, 10, 30, 0, 0, time.Local)},
+		{"0 9-17 * * mon-fri", time.Date(2024, time.January, 31, 11, 0, 0, 0, time.Local)},
+		{"30 2 * * *", time.Date(2024, time.February, 1, 2, 30, 0, 0, time.Local)},
+		{"0 0 29 feb *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.Local)},
+		{"0 0 1,15 * *", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.Local)},
+		{"0 0 * * 7", time.Date(2024, time.February, 4, 0, 0, 0, 0, time.Local)},
+		{"0 0 13 * fri", time.Date(2024, time.February, 2, 0, 0, 0, 0, time.Local)},
+		{"0 0 */2 * 1", time.Date(2024, time.February, 5, 0, 0, 0, 0, time.Local)},
+		{"0 0 13 * */2", time.Date(2024, time.February, 13, 0, 0, 0, 0, time.Local)},
+		{"@hourly", time.Date(2024, time.January, 31, 11, 0, 0, 0, time.Local)},
+		{"@monthly", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.Local)},
+		{"@every 90s", from.Add(90 * time.Second)},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if got := schedule.Next(from); !got.Equal(tt.want) {
+			t.Errorf("ParseCron(%q).Next = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * funday",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"@every soon",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("Expected ParseCron(%q) to fail", expr)
+		}
+	}
+}
+
+func TestParseCron_Impossible(t *testing.T) {
+	schedule := MustParseCron("0 0 31 feb *")
+	if next := schedule.Next(time.Now()); !next.IsZero() {
+		t.Errorf("Expected no fire time for February 31st, got %v", next)
+	}
+}
@@ -0,0 +1,211 @@
+package task
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Priority orders chunks waiting for a worker; higher priorities are dispatched first
+type Priority int
+
+const (
+	// PriorityLow is for background work that yields to everything else
+	PriorityLow Priority = -1
+	// PriorityNormal is the priority of chunks without ContextWithPriority
+	PriorityNormal Priority = 0
+	// PriorityHigh is for latency sensitive work dispatched ahead of the others
+	PriorityHigh Priority = 1
+)
+
+type groupKey struct{}
+
+type priorityKey struct{}
+
+// ContextWithGroup returns a copy of ctx that makes ProcessInChunks account its chunks
+// to the named group. Groups share workers according to their weights and limits.
+func ContextWithGroup(ctx context.Context, group string) context.Context {
+	return context.WithValue(ctx, groupKey{}, group)
+}
+
+// ContextWithPriority returns a copy of ctx that makes ProcessInChunks dispatch its
+// chunks with the given priority.
+func ContextWithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// groupFromContext returns the group and priority stored in ctx, if any.
+func groupFromContext(ctx context.Context) (string, Priority) {
+	group, _ := ctx.Value(groupKey{}).(string)
+	priority, _ := ctx.Value(priorityKey{}).(Priority)
+	return group, priority
+}
+
+// slotWaiter is a chunk waiting for a worker slot
+type slotWaiter struct {
+	priority Priority
+	enqueued time.Time
+	ready    chan struct{} // closed once the slot is granted
+	granted  bool
+}
+
+// groupState tracks the slots and waiters of a single group
+type groupState struct {
+	weight  float64
+	limit   int           // maximum concurrent slots, zero means unlimited
+	running int           // slots currently held
+	vtime   float64       // virtual finish time for weighted fair queuing
+	waiters []*slotWaiter // FIFO of waiting chunks
+}
+
+// slotScheduler hands out worker slots by priority, then weighted fair share among
+// groups. Waiters are promoted one priority level for every aging period they wait, so
+// low priority work cannot starve.
+type slotScheduler struct {
+	mu      sync.Mutex
+	free    int
+	aging   time.Duration
+	vclock  float64 // virtual time of the last granted slot
+	groups  map[string]*groupState
+	weights map[string]int
+	limits  map[string]int
+}
+
+func newSlotScheduler(slots int, weights, limits map[string]int, aging time.Duration) *slotScheduler {
+	return &slotScheduler{
+		free:    slots,
+		aging:   aging,
+		groups:  make(map[string]*groupState),
+		weights: weights,
+		limits:  limits,
+	}
+}
+
+// group returns the state of the named group, creating it on first use. Locked.
+func (s *slotScheduler) group(name string) *groupState {
+	g, ok := s.groups[name]
+	if !ok {
+		g = &groupState{weight: 1, limit: s.limits[name]}
+		if w := s.weights[name]; w > 0 {
+			g.weight = float64(w)
+		}
+		s.groups[name] = g
+	}
+	return g
+}
+
+// acquire blocks until a worker slot is granted to the group or ctx is done.
+func (s *slotScheduler) acquire(ctx context.Context, group string, priority Priority) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	g := s.group(group)
+	if g.running == 0 && len(g.waiters) == 0 {
+		// An idle group must not bank credit from the time it was idle
+		g.vtime = max(g.vtime, s.vclock)
+	}
+	w := &slotWaiter{priority: priority, enqueued: time.Now(), ready: make(chan struct{})}
+	g.waiters = append(g.waiters, w)
+	s.dispatch()
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if w.granted {
+			// Granted concurrently with the cancellation, hand the slot back
+			g.running--
+			s.free++
+			s.dispatch()
+		} else {
+			for i, waiter := range g.waiters {
+				if waiter == w {
+					g.waiters = append(g.waiters[:i], g.waiters[i+1:]...)
+					break
+				}
+			}
+		}
+		s.prune(group, g)
+		return ctx.Err()
+	}
+}
+
+// release returns a worker slot held by the group.
+func (s *slotScheduler) release(group string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.group(group)
+	g.running--
+	s.free++
+	s.dispatch()
+	s.prune(group, g)
+}
+
+// prune forgets the state of an idle group, so that groups keyed by request or tenant
+// do not accumulate. It is created again on its next use. Locked.
+func (s *slotScheduler) prune(name string, g *groupState) {
+	if g.running == 0 && len(g.waiters) == 0 {
+		delete(s.groups, name)
+	}
+}
+
+// dispatch grants free slots to the best waiters. Locked.
+func (s *slotScheduler) dispatch() {
+	now := time.Now()
+	for s.free > 0 {
+		var (
+			best      *groupState
+			bestIndex int
+			bestPrio  Priority
+		)
+		for _, g := range s.groups {
+			if len(g.waiters) == 0 || (g.limit > 0 && g.running >= g.limit) {
+				continue
+			}
+
+			// Best waiter of the group: highest effective priority, then oldest
+			index, prio := 0, s.effective(g.waiters[0], now)
+			for i, w := range g.waiters[1:] {
+				if p := s.effective(w, now); p > prio {
+					index, prio = i+1, p
+				}
+			}
+
+			if best == nil || prio > bestPrio ||
+				(prio == bestPrio && g.vtime < best.vtime) ||
+				(prio == bestPrio && g.vtime == best.vtime &&
+					g.waiters[index].enqueued.Before(best.waiters[bestIndex].enqueued)) {
+				best, bestIndex, bestPrio = g, index, prio
+			}
+		}
+		if best == nil {
+			return // nothing eligible is waiting
+		}
+
+		w := best.waiters[bestIndex]
+		best.waiters = append(best.waiters[:bestIndex], best.waiters[bestIndex+1:]...)
+		best.running++
+		best.vtime += 1 / best.weight
+		s.vclock = best.vtime
+		s.free--
+
+		w.granted = true
+		close(w.ready)
+	}
+}
+
+// effective returns the priority of w after aging.
+func (s *slotScheduler) effective(w *slotWaiter, now time.Time) Priority {
+	if s.aging <= 0 {
+		return w.priority
+	}
+	return w.priority + Priority(now.Sub(w.enqueued)/s.aging)
+}
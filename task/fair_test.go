@@ -0,0 +1,223 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForWaiters blocks until the scheduler has n waiting chunks.
+func waitForWaiters(s *slotScheduler, n int) {
+	for {
+		s.mu.Lock()
+		waiting := 0
+		for _, g := range s.groups {
+			waiting += len(g.waiters)
+		}
+		s.mu.Unlock()
+
+		if waiting >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// queuedChunk describes a chunk waiting for a worker slot
+type queuedChunk struct {
+	group    string
+	priority Priority
+}
+
+// grantOrder queues the given waiters one at a time behind a held slot, releases the
+// slot and returns the order in which the waiters were granted.
+func grantOrder(s *slotScheduler, waiters []queuedChunk) []int {
+	_ = s.acquire(context.Background(), "", PriorityNormal)
+
+	var (
+		mu    sync.Mutex
+		order []int
+		wg    sync.WaitGroup
+	)
+	for i, w := range waiters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = s.acquire(context.Background(), w.group, w.priority)
+
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			s.release(w.group)
+		}()
+		waitForWaiters(s, i+1)
+	}
+
+	s.release("")
+	wg.Wait()
+	return order
+}
+
+func TestSlotScheduler_Priority(t *testing.T) {
+	s := newSlotScheduler(1, nil, nil, 0)
+
+	order := grantOrder(s, []queuedChunk{
+		{"", PriorityLow},
+		{"", PriorityNormal},
+		{"", PriorityHigh},
+	})
+	if order[0] != 2 || order[1] != 1 || order[2] != 0 {
+		t.Errorf("Expected high, normal, low, got waiter order %v", order)
+	}
+}
+
+func TestSlotScheduler_WeightedFairness(t *testing.T) {
+	s := newSlotScheduler(1, map[string]int{"big": 1, "small": 1}, nil, 0)
+
+	// The big tenant queues 6 chunks before the small tenant queues 2
+	waiters := make([]queuedChunk, 0, 8)
+	for range 6 {
+		waiters = append(waiters, queuedChunk{"big", PriorityNormal})
+	}
+	for range 2 {
+		waiters = append(waiters, queuedChunk{"small", PriorityNormal})
+	}
+
+	order := grantOrder(s, waiters)
+	for pos, i := range order {
+		if i >= 6 && pos > 4 {
+			t.Errorf("Expected the small tenant to interleave with the big one, got order %v", order)
+			break
+		}
+	}
+}
+
+func TestSlotScheduler_StarvationProtection(t *testing.T) {
+	s := newSlotScheduler(1, nil, nil, 10*time.Millisecond)
+
+	_ = s.acquire(context.Background(), "", PriorityNormal)
+	lowGranted := make(chan struct{})
+	go func() {
+		_ = s.acquire(context.Background(), "low", PriorityLow)
+		close(lowGranted)
+	}()
+	waitForWaiters(s, 1)
+	time.Sleep(30 * time.Millisecond) // the low waiter ages past normal priority
+
+	highGranted := make(chan struct{})
+	go func() {
+		_ = s.acquire(context.Background(), "normal", PriorityNormal)
+		close(highGranted)
+	}()
+	waitForWaiters(s, 2)
+
+	s.release("")
+	select {
+	case <-lowGranted:
+	case <-highGranted:
+		t.Errorf("Expected the aged low priority waiter to be granted first")
+	}
+}
+
+func TestSlotScheduler_Cancel(t *testing.T) {
+	s := newSlotScheduler(1, nil, nil, 0)
+	_ = s.acquire(context.Background(), "", PriorityNormal)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.acquire(ctx, "", PriorityNormal); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	s.release("")
+	if err := s.acquire(context.Background(), "", PriorityNormal); err != nil {
+		t.Errorf("Expected the slot to be available after cancellation, got %v", err)
+	}
+}
+
+func TestTaskProcessor_GroupLimit(t *testing.T) {
+	processor := NewTaskProcessor(
+		WithMaxWorkerCount(4),
+		WithChunkStrategy(FixedChunkSize(1)),
+		WithGroupLimit("tenant-a", 1),
+	)
+
+	var running, peak atomic.Int32
+	ctx := ContextWithGroup(context.Background(), "tenant-a")
+	err := processor.ProcessInChunks(ctx, make([]any, 8),
+		func(_ context.Context, _ []any) (any, error) {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+			return nil, nil
+		})
+	if err != nil {
+		t.Fatalf("ProcessInChunks failed: %v", err)
+	}
+	if p := peak.Load(); p != 1 {
+		t.Errorf("Expected tenant-a to use at most 1 worker, peak was %d", p)
+	}
+}
+
+func TestTaskProcessor_ZeroWorkers(t *testing.T) {
+	processor := NewTaskProcessor(WithMaxWorkerCount(0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var processed atomic.Int32
+	err := processor.ProcessInChunks(ctx, make([]any, 4),
+		func(_ context.Context, chunk []any) (any, error) {
+			processed.Add(int32(len(chunk))) //nolint:gosec // tiny chunks
+			return nil, nil
+		})
+	if err != nil {
+		t.Fatalf("ProcessInChunks failed: %v", err)
+	}
+	if n := processed.Load(); n != 4 {
+		t.Errorf("Expected 4 processed tasks, got %d", n)
+	}
+}
+
+func TestSlotScheduler_PrunesIdleGroups(t *testing.T) {
+	s := newSlotScheduler(2, nil, nil, 0)
+
+	// Groups keyed by request must not accumulate once their work is done
+	for i := range 100 {
+		group := fmt.Sprintf("request-%d", i)
+		if err := s.acquire(context.Background(), group, PriorityNormal); err != nil {
+			t.Fatalf("acquire failed: %v", err)
+		}
+		s.release(group)
+	}
+
+	// Neither must the group of a cancelled waiter
+	_ = s.acquire(context.Background(), "busy", PriorityNormal)
+	_ = s.acquire(context.Background(), "busy", PriorityNormal)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.acquire(ctx, "cancelled", PriorityNormal) }()
+	waitForWaiters(s, 1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	s.release("busy")
+	s.release("busy")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.groups) != 0 {
+		t.Errorf("Expected idle groups to be pruned, got %d", len(s.groups))
+	}
+}
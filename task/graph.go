@@ -0,0 +1,292 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrGraphCycle is returned when the dependencies of a Graph form a cycle
+	ErrGraphCycle = errors.New("task: dependency cycle")
+	// ErrUnknownDependency is returned when a node depends on a node that was never added
+	ErrUnknownDependency = errors.New("task: unknown dependency")
+)
+
+// NodeStatus is the state of a Graph node after a run
+type NodeStatus int
+
+const (
+	NodePending   NodeStatus = iota // not run yet
+	NodeSucceeded                   // finished without error
+	NodeFailed                      // finished with an error
+	NodeSkipped                     // not run because an upstream node did not succeed
+	NodeCanceled                    // not run because the run context was cancelled
+)
+
+func (s NodeStatus) String() string {
+	switch s {
+	case NodePending:
+		return "pending"
+	case NodeSucceeded:
+		return "succeeded"
+	case NodeFailed:
+		return "failed"
+	case NodeSkipped:
+		return "skipped"
+	case NodeCanceled:
+		return "canceled"
+	default:
+		return fmt.Sprintf("NodeStatus(%d)", int(s))
+	}
+}
+
+// NodeResult is the outcome of a single Graph node
+type NodeResult struct {
+	Name     string
+	Status   NodeStatus
+	Err      error         // error returned by the node, if it failed
+	Start    time.Time     // when the node started, zero if it never ran
+	Duration time.Duration // how long the node ran
+}
+
+// GraphReport summarizes a Graph run
+type GraphReport struct {
+	Nodes    []NodeResult  // node results in topological order
+	Duration time.Duration // wall time of the whole run
+}
+
+// Node returns the result of the named node.
+func (r *GraphReport) Node(name string) (NodeResult, bool) {
+	for _, n := range r.Nodes {
+		if n.Name == name {
+			return n, true
+		}
+	}
+	return NodeResult{}, false
+}
+
+// Summary renders the report as a human-readable table, one node per line.
+func (r *GraphReport) Summary() string {
+	width := len("NODE")
+	for _, n := range r.Nodes {
+		width = max(width, len(n.Name))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-*s  %-9s  %s\n", width, "NODE", "STATUS", "DURATION")
+	for _, n := range r.Nodes {
+		fmt.Fprintf(&b, "%-*s  %-9s  %v", width, n.Name, n.Status, n.Duration.Round(time.Millisecond))
+		if n.Err != nil {
+			fmt.Fprintf(&b, "  %v", n.Err)
+		}
+		b.WriteByte('\n')
+	}
+	fmt.Fprintf(&b, "total %v\n", r.Duration.Round(time.Millisecond))
+	return b.String()
+}
+
+type graphNode struct {
+	name string
+	fn   func(ctx context.Context) error
+	deps []string
+}
+
+// Graph is a set of named tasks with dependencies between them.
+// Run executes every task after all of its dependencies have succeeded.
+type Graph struct {
+	nodes map[string]*graphNode
+	order []string // insertion order, keeps runs deterministic
+}
+
+// NewGraph creates an empty Graph
+func NewGraph() *Graph {
+	return &Graph{nodes: make(map[string]*graphNode)}
+}
+
+// Add adds a task named name that runs after every node in deps has succeeded.
+// Dependencies may be added later; they are checked by Validate and Run.
+func (g *Graph) Add(name string, fn func(ctx context.Context) error, deps ...string) error {
+	if _, ok := g.nodes[name]; ok {
+		return fmt.Errorf("task: duplicate graph node %q", name)
+	}
+
+	g.nodes[name] = &graphNode{name: name, fn: fn, deps: deps}
+	g.order = append(g.order, name)
+	return nil
+}
+
+// Validate checks that every dependency exists and that there is no cycle.
+func (g *Graph) Validate() error {
+	_, err := g.topoSort()
+	return err
+}
+
+// topoSort returns the node names in a dependency-respecting order.
+func (g *Graph) topoSort() ([]string, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int, len(g.nodes))
+	sorted := make([]string, 0, len(g.nodes))
+	var path []string
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			// Report the cycle starting from its first occurrence on the path
+			i := len(path) - 1
+			for path[i] != name {
+				i--
+			}
+			return fmt.Errorf("%w: %s", ErrGraphCycle, strings.Join(append(path[i:], name), " -> "))
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range g.nodes[name].deps {
+			if _, ok := g.nodes[dep]; !ok {
+				return fmt.Errorf("%w: %q depends on %q", ErrUnknownDependency, name, dep)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+
+		sorted = append(sorted, name)
+		return nil
+	}
+
+	for _, name := range g.order {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+type nodeDone struct {
+	name  string
+	err   error
+	start time.Time
+	end   time.Time
+}
+
+// Run validates the graph and executes its nodes with at most maxWorkers running at
+// once; a non-positive maxWorkers means one per logical CPU. Nodes whose upstream failed
+// are skipped, and nodes not started when ctx is cancelled are marked canceled.
+//
+// The returned error joins the errors of every failed node and the context error, if
+// any. The report is nil only when validation fails.
+func (g *Graph) Run(ctx context.Context, maxWorkers int) (*GraphReport, error) {
+	sorted, err := g.topoSort()
+	if err != nil {
+		return nil, err
+	}
+	if maxWorkers <= 0 {
+		maxWorkers = runtime.GOMAXPROCS(0)
+	}
+
+	begin := time.Now()
+	results := make(map[string]*NodeResult, len(g.nodes))
+	indegree := make(map[string]int, len(g.nodes))
+	dependents := make(map[string][]string, len(g.nodes))
+	for _, name := range g.order {
+		results[name] = &NodeResult{Name: name}
+		indegree[name] = len(g.nodes[name].deps)
+		for _, dep := range g.nodes[name].deps {
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+
+	ready := make([]string, 0, len(g.nodes))
+	for _, name := range g.order {
+		if indegree[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	// skip marks every pending node downstream of name as skipped
+	var skip func(name string)
+	skip = func(name string) {
+		for _, dependent := range dependents[name] {
+			if results[dependent].Status == NodePending {
+				results[dependent].Status = NodeSkipped
+				skip(dependent)
+			}
+		}
+	}
+
+	done := make(chan nodeDone)
+	running := 0
+	for {
+		// Start as many ready nodes as the worker limit allows
+		for len(ready) > 0 && running < maxWorkers && ctx.Err() == nil {
+			node := g.nodes[ready[0]]
+			ready = ready[1:]
+			running++
+
+			go func() {
+				start := time.Now()
+				_, err := safeCall(ctx, func(ctx context.Context) (struct{}, error) {
+					return struct{}{}, node.fn(ctx)
+				})
+				done <- nodeDone{name: node.name, err: err, start: start, end: time.Now()}
+			}()
+		}
+		if running == 0 {
+			break
+		}
+
+		d := <-done
+		running--
+
+		result := results[d.name]
+		result.Start, result.Duration = d.start, d.end.Sub(d.start)
+		if d.err != nil {
+			result.Status, result.Err = NodeFailed, d.err
+			skip(d.name)
+			continue
+		}
+
+		result.Status = NodeSucceeded
+		for _, dependent := range dependents[d.name] {
+			indegree[dependent]--
+			if indegree[dependent] == 0 && results[dependent].Status == NodePending {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	report := &GraphReport{Nodes: make([]NodeResult, 0, len(sorted)), Duration: time.Since(begin)}
+	var (
+		errs     []error
+		canceled bool
+	)
+	for _, name := range sorted {
+		result := results[name]
+		if result.Status == NodePending {
+			result.Status = NodeCanceled
+			canceled = true
+		}
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("node %q: %w", name, result.Err))
+		}
+		report.Nodes = append(report.Nodes, *result)
+	}
+	if canceled {
+		errs = append(errs, ctx.Err())
+	}
+	return report, errors.Join(errs...)
+}
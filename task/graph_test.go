@@ -0,0 +1,124 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGraph_Run(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	record := func(name string) func(context.Context) error {
+		return func(_ context.Context) error {
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	g := NewGraph()
+	_ = g.Add("deploy", record("deploy"), "build", "migrate")
+	_ = g.Add("build", record("build"), "fetch")
+	_ = g.Add("migrate", record("migrate"), "fetch")
+	_ = g.Add("fetch", record("fetch"))
+
+	report, err := g.Run(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	index := func(name string) int { return slices.Index(order, name) }
+	if index("fetch") != 0 || index("deploy") != 3 {
+		t.Errorf("Dependencies not respected, execution order: %v", order)
+	}
+	for _, n := range report.Nodes {
+		if n.Status != NodeSucceeded || n.Duration <= 0 {
+			t.Errorf("Expected %s to succeed with a duration, got %v in %v", n.Name, n.Status, n.Duration)
+		}
+	}
+	if !strings.Contains(report.Summary(), "deploy") {
+		t.Errorf("Expected summary to list every node, got:\n%s", report.Summary())
+	}
+}
+
+func TestGraph_FailureSkipsDownstream(t *testing.T) {
+	errMigrate := errors.New("migration failed")
+	var ran atomic.Int32
+	ok := func(_ context.Context) error { ran.Add(1); return nil }
+
+	g := NewGraph()
+	_ = g.Add("fetch", ok)
+	_ = g.Add("migrate", func(_ context.Context) error { return errMigrate }, "fetch")
+	_ = g.Add("build", ok, "fetch")
+	_ = g.Add("deploy", ok, "build", "migrate")
+	_ = g.Add("notify", ok, "deploy")
+
+	report, err := g.Run(context.Background(), 4)
+	if !errors.Is(err, errMigrate) {
+		t.Fatalf("Expected migration error, got %v", err)
+	}
+
+	want := map[string]NodeStatus{
+		"fetch":   NodeSucceeded,
+		"migrate": NodeFailed,
+		"build":   NodeSucceeded,
+		"deploy":  NodeSkipped,
+		"notify":  NodeSkipped,
+	}
+	for name, status := range want {
+		if n, _ := report.Node(name); n.Status != status {
+			t.Errorf("Expected %s to be %v, got %v", name, status, n.Status)
+		}
+	}
+	if ran.Load() != 2 {
+		t.Errorf("Expected 2 successful nodes to run, got %d", ran.Load())
+	}
+}
+
+func TestGraph_Validate(t *testing.T) {
+	noop := func(_ context.Context) error { return nil }
+
+	g := NewGraph()
+	_ = g.Add("a", noop, "c")
+	_ = g.Add("b", noop, "a")
+	_ = g.Add("c", noop, "b")
+	if _, err := g.Run(context.Background(), 1); !errors.Is(err, ErrGraphCycle) {
+		t.Errorf("Expected ErrGraphCycle, got %v", err)
+	}
+
+	g = NewGraph()
+	_ = g.Add("a", noop, "missing")
+	if err := g.Validate(); !errors.Is(err, ErrUnknownDependency) {
+		t.Errorf("Expected ErrUnknownDependency, got %v", err)
+	}
+
+	if err := g.Add("a", noop); err == nil {
+		t.Errorf("Expected an error for a duplicate node")
+	}
+}
+
+func TestGraph_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	g := NewGraph()
+	_ = g.Add("first", func(_ context.Context) error { cancel(); return nil })
+	_ = g.Add("second", func(_ context.Context) error { return nil }, "first")
+
+	report, err := g.Run(ctx, 1)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if n, _ := report.Node("second"); n.Status != NodeCanceled {
+		t.Errorf("Expected second to be canceled, got %v", n.Status)
+	}
+}
@@ -0,0 +1,117 @@
+package task
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter bounds the rate at which task functions are called.
+// A Limiter is safe for concurrent use and may be shared by several processors.
+type Limiter interface {
+	// Wait blocks until the caller is allowed to proceed or ctx is done.
+	Wait(ctx context.Context) error
+}
+
+// TokenBucket is a Limiter that refills tokens at a constant rate up to a burst size.
+// Every call to Wait consumes one token.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64   // tokens added per second
+	burst  float64   // bucket capacity
+	tokens float64   // available tokens, negative when callers are waiting
+	last   time.Time // last refill time
+}
+
+// NewTokenBucket creates a TokenBucket allowing rps calls per second on average and
+// bursts of up to burst calls. A non-positive rps disables limiting.
+func NewTokenBucket(rps float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		rate:   rps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait implements Limiter
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	if b.rate <= 0 {
+		return ctx.Err()
+	}
+
+	// Reserve a token, possibly going into debt, and wait for the debt to be repaid
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	var wait time.Duration
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if err := sleepContext(ctx, wait); err != nil {
+		// Give the reservation back
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// SlidingWindow is a Limiter that allows at most limit calls within any window duration
+type SlidingWindow struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	calls  []time.Time // start times of the calls in the current window, oldest first
+}
+
+// NewSlidingWindow creates a SlidingWindow allowing limit calls per window.
+func NewSlidingWindow(limit int, window time.Duration) *SlidingWindow {
+	if limit < 1 {
+		limit = 1
+	}
+	return &SlidingWindow{
+		limit:  limit,
+		window: window,
+		calls:  make([]time.Time, 0, limit),
+	}
+}
+
+// Wait implements Limiter
+func (w *SlidingWindow) Wait(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		w.mu.Lock()
+		now := time.Now()
+
+		// Forget calls that left the window
+		expired := 0
+		for expired < len(w.calls) && now.Sub(w.calls[expired]) >= w.window {
+			expired++
+		}
+		w.calls = append(w.calls[:0], w.calls[expired:]...)
+
+		if len(w.calls) < w.limit {
+			w.calls = append(w.calls, now)
+			w.mu.Unlock()
+			return nil
+		}
+		wait := w.window - now.Sub(w.calls[0])
+		w.mu.Unlock()
+
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
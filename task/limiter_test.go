@@ -0,0 +1,73 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	limiter := NewTokenBucket(100, 5)
+
+	// The burst passes without waiting, the rest is paced at 10ms per call
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected calls beyond the burst to be throttled, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	slow := NewTokenBucket(0.1, 1)
+	_ = slow.Wait(context.Background())
+	if err := slow.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	limiter := NewSlidingWindow(3, 30*time.Millisecond)
+
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Expected the second group of calls to wait for the window, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestTaskProcessor_WithRateLimit(t *testing.T) {
+	processor := NewTaskProcessor(
+		WithMaxWorkerCount(4),
+		WithChunkStrategy(FixedChunkSize(1)),
+		WithRateLimit(200, 1),
+	)
+
+	err := processor.ProcessInChunks(context.Background(), make([]any, 10),
+		func(_ context.Context, _ []any) (any, error) { return nil, nil })
+	if err != nil {
+		t.Fatalf("ProcessInChunks failed: %v", err)
+	}
+
+	stats := processor.Metrics()
+	if stats.CompletedTasks != 10 {
+		t.Errorf("Expected 10 completed tasks, got %d", stats.CompletedTasks)
+	}
+	if stats.ThrottledTime <= 0 {
+		t.Errorf("Expected throttled time to be recorded, got %v", stats.ThrottledTime)
+	}
+}
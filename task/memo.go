@@ -0,0 +1,234 @@
+package task
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MemoStats is a point-in-time snapshot of a Memo's metrics
+type MemoStats struct {
+	Hits      uint64 // lookups served from the cache
+	Misses    uint64 // lookups that had to compute the value
+	Shared    uint64 // misses that waited for a computation already in progress
+	Evictions uint64 // entries evicted to respect the size limit
+	Expired   uint64 // entries dropped because their TTL elapsed
+	Entries   int    // entries currently cached
+}
+
+// HitRatio returns the fraction of lookups served from the cache.
+func (s MemoStats) HitRatio() float64 {
+	if total := s.Hits + s.Misses; total > 0 {
+		return float64(s.Hits) / float64(total)
+	}
+	return 0
+}
+
+// MemoOption defines the functional option type for Memo configuration
+type MemoOption func(*memoConfig)
+
+type memoConfig struct {
+	ttl        time.Duration
+	maxEntries int
+	clock      Clock
+}
+
+// WithTTL configures how long a cached value stays valid, zero means forever
+func WithTTL(d time.Duration) MemoOption {
+	return func(c *memoConfig) { c.ttl = d }
+}
+
+// WithMaxEntries configures the maximum number of cached values, the least recently
+// used one is evicted first. Zero means unlimited.
+func WithMaxEntries(n int) MemoOption {
+	return func(c *memoConfig) { c.maxEntries = n }
+}
+
+// WithMemoClock configures the clock used for TTLs
+func WithMemoClock(clock Clock) MemoOption {
+	return func(c *memoConfig) { c.clock = clock }
+}
+
+type memoEntry[K comparable, V any] struct {
+	key        K
+	value      V
+	expires    time.Time // zero if the entry never expires
+	prev, next *memoEntry[K, V]
+}
+
+// Memo caches the results of an expensive computation by key, with an optional TTL and
+// LRU size limit. Concurrent misses for the same key share a single computation.
+// Errors are not cached.
+type Memo[K comparable, V any] struct {
+	cfg   memoConfig
+	group Group[K, V]
+
+	mu      sync.Mutex
+	entries map[K]*memoEntry[K, V]
+	lru     memoEntry[K, V] // sentinel of the LRU list, most recently used after it
+
+	hits, misses, shared, evictions, expired atomic.Uint64
+}
+
+// NewMemo creates an empty Memo
+func NewMemo[K comparable, V any](options ...MemoOption) *Memo[K, V] {
+	cfg := memoConfig{clock: SystemClock}
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	m := &Memo[K, V]{cfg: cfg, entries: make(map[K]*memoEntry[K, V])}
+	m.lru.prev, m.lru.next = &m.lru, &m.lru
+	return m
+}
+
+// Do returns the cached value of key, or computes it with fn and caches it if fn
+// succeeds. Concurrent calls for a missing key wait for a single call of fn.
+func (m *Memo[K, V]) Do(ctx context.Context, key K, fn func(ctx context.Context) (V, error)) (V, error) {
+	if v, ok := m.Get(key); ok {
+		return v, nil
+	}
+
+	m.misses.Add(1)
+	v, err, shared := m.group.Do(ctx, key, func(ctx context.Context) (V, error) {
+		// Another computation may have finished since the lookup
+		if v, ok := m.get(key); ok {
+			return v, nil
+		}
+
+		v, err := fn(ctx)
+		if err == nil {
+			m.Set(key, v)
+		}
+		return v, err
+	})
+	if shared {
+		m.shared.Add(1)
+	}
+	return v, err
+}
+
+// Wrap returns a TaskFunc that caches the results of fn by the key of each chunk, so
+// chunks with the same key are only computed once while cached.
+func (m *Memo[K, V]) Wrap(
+	key func(chunk []any) K, fn func(ctx context.Context, chunk []any) (V, error),
+) TaskFunc {
+	return func(ctx context.Context, chunk []any) (any, error) {
+		return m.Do(ctx, key(chunk), func(ctx context.Context) (V, error) { return fn(ctx, chunk) })
+	}
+}
+
+// Get returns the cached value of key, if it is cached and not expired.
+func (m *Memo[K, V]) Get(key K) (V, bool) {
+	v, ok := m.get(key)
+	if ok {
+		m.hits.Add(1)
+	}
+	return v, ok
+}
+
+// get looks key up without counting a hit.
+func (m *Memo[K, V]) get(key K) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, ok := m.entries[key]; ok {
+		if entry.expires.IsZero() || m.cfg.clock.Now().Before(entry.expires) {
+			m.unlink(entry)
+			m.pushFront(entry)
+			return entry.value, true
+		}
+
+		m.remove(entry)
+		m.expired.Add(1)
+	}
+
+	var zero V
+	return zero, false
+}
+
+// Set caches value for key, evicting the least recently used entry if the cache is full.
+func (m *Memo[K, V]) Set(key K, value V) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expires time.Time
+	if m.cfg.ttl > 0 {
+		expires = m.cfg.clock.Now().Add(m.cfg.ttl)
+	}
+
+	if entry, ok := m.entries[key]; ok {
+		entry.value, entry.expires = value, expires
+		m.unlink(entry)
+		m.pushFront(entry)
+		return
+	}
+
+	entry := &memoEntry[K, V]{key: key, value: value, expires: expires}
+	m.entries[key] = entry
+	m.pushFront(entry)
+	for m.cfg.maxEntries > 0 && len(m.entries) > m.cfg.maxEntries {
+		m.remove(m.lru.prev)
+		m.evictions.Add(1)
+	}
+}
+
+// Invalidate removes key from the cache.
+func (m *Memo[K, V]) Invalidate(key K) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, ok := m.entries[key]; ok {
+		m.remove(entry)
+	}
+}
+
+// Purge removes every entry from the cache. Metrics are kept.
+func (m *Memo[K, V]) Purge() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	clear(m.entries)
+	m.lru.prev, m.lru.next = &m.lru, &m.lru
+}
+
+// Len returns the number of cached entries, including expired ones not dropped yet.
+func (m *Memo[K, V]) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.entries)
+}
+
+// Stats returns a snapshot of the cache metrics.
+func (m *Memo[K, V]) Stats() MemoStats {
+	return MemoStats{
+		Hits:      m.hits.Load(),
+		Misses:    m.misses.Load(),
+		Shared:    m.shared.Load(),
+		Evictions: m.evictions.Load(),
+		Expired:   m.expired.Load(),
+		Entries:   m.Len(),
+	}
+}
+
+// remove drops entry from the cache. Locked.
+func (m *Memo[K, V]) remove(entry *memoEntry[K, V]) {
+	m.unlink(entry)
+	delete(m.entries, entry.key)
+}
+
+// pushFront inserts entry as the most recently used. Locked.
+func (m *Memo[K, V]) pushFront(entry *memoEntry[K, V]) {
+	entry.prev, entry.next = &m.lru, m.lru.next
+	m.lru.next.prev = entry
+	m.lru.next = entry
+}
+
+// unlink removes entry from the LRU list. Locked.
+func (m *Memo[K, V]) unlink(entry *memoEntry[K, V]) {
+	entry.prev.next = entry.next
+	entry.next.prev = entry.prev
+	entry.prev, entry.next = nil, nil
+}
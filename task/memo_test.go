@@ -0,0 +1,119 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemo_TTL(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	m := NewMemo[string, int](WithTTL(time.Minute), WithMemoClock(clock))
+
+	calls := 0
+	compute := func(context.Context) (int, error) {
+		calls++
+		return calls, nil
+	}
+
+	for range 3 {
+		if v, _ := m.Do(context.Background(), "a", compute); v != 1 {
+			t.Errorf("Expected the cached value 1, got %d", v)
+		}
+	}
+	clock.Advance(time.Minute)
+	if v, _ := m.Do(context.Background(), "a", compute); v != 2 {
+		t.Errorf("Expected a recomputed value after the TTL, got %d", v)
+	}
+
+	stats := m.Stats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Expired != 1 || stats.Entries != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if r := stats.HitRatio(); r != 0.5 {
+		t.Errorf("Expected a hit ratio of 0.5, got %v", r)
+	}
+}
+
+func TestMemo_LRU(t *testing.T) {
+	m := NewMemo[int, int](WithMaxEntries(2))
+	m.Set(1, 1)
+	m.Set(2, 2)
+	m.Get(1) // 2 is now the least recently used
+	m.Set(3, 3)
+
+	if _, ok := m.Get(2); ok {
+		t.Errorf("Expected 2 to be evicted")
+	}
+	for _, key := range []int{1, 3} {
+		if _, ok := m.Get(key); !ok {
+			t.Errorf("Expected %d to be cached", key)
+		}
+	}
+	if s := m.Stats(); s.Evictions != 1 || s.Entries != 2 {
+		t.Errorf("Expected 1 eviction and 2 entries, got %+v", s)
+	}
+
+	m.Invalidate(1)
+	if _, ok := m.Get(1); ok {
+		t.Errorf("Expected 1 to be invalidated")
+	}
+	m.Purge()
+	if m.Len() != 0 {
+		t.Errorf("Expected an empty cache after Purge, got %d entries", m.Len())
+	}
+}
+
+func TestMemo_ErrorsNotCached(t *testing.T) {
+	m := NewMemo[string, int]()
+	errBoom := errors.New("boom")
+
+	fail := func(context.Context) (int, error) { return 0, errBoom }
+	succeed := func(context.Context) (int, error) { return 7, nil }
+
+	if _, err := m.Do(context.Background(), "a", fail); !errors.Is(err, errBoom) {
+		t.Fatalf("Expected errBoom, got %v", err)
+	}
+	if v, err := m.Do(context.Background(), "a", succeed); v != 7 || err != nil {
+		t.Errorf("Expected the failed computation not to be cached, got %d, %v", v, err)
+	}
+}
+
+func TestMemo_Wrap(t *testing.T) {
+	m := NewMemo[string, any]()
+	var calls atomic.Int32
+
+	results := make(chan any, 8)
+	processor := NewTaskProcessor(
+		WithMaxWorkerCount(4),
+		WithChunkStrategy(FixedChunkSize(1)),
+		WithResultHandler(func(result any) { results <- result }),
+	)
+
+	// Eight chunks with only two distinct keys
+	tasks := []any{1, 2, 1, 2, 1, 2, 1, 2}
+	key := func(chunk []any) string { return strconv.Itoa(chunk[0].(int)) }
+	err := processor.ProcessInChunks(context.Background(), tasks, m.Wrap(key,
+		func(_ context.Context, chunk []any) (any, error) {
+			calls.Add(1)
+			return chunk[0].(int) * 10, nil
+		}))
+	if err != nil {
+		t.Fatalf("ProcessInChunks failed: %v", err)
+	}
+	close(results)
+
+	sum := 0
+	for r := range results {
+		sum += r.(int)
+	}
+	if sum != 120 {
+		t.Errorf("Expected results to sum to 120, got %d", sum)
+	}
+	if c := calls.Load(); c > 2 {
+		t.Errorf("Expected at most 2 computations, got %d", c)
+	}
+}
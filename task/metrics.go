@@ -0,0 +1,254 @@
+package task
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the chunk latency histogram buckets,
+// doubling from 100µs to roughly 105s.
+var latencyBuckets = func() []time.Duration {
+	bounds := make([]time.Duration, 21)
+	for i := range bounds {
+		bounds[i] = 100 * time.Microsecond << i
+	}
+	return bounds
+}()
+
+// ProcessorMetrics is a point-in-time snapshot of the processor's metrics.
+// Counters accumulate since the processor was created or since the last Reset.
+type ProcessorMetrics struct {
+	CompletedTasks uint64 // number of successfully completed task function calls
+	ErrorCount     uint64 // number of task function calls that finally failed
+	RetryCount     uint64 // number of failed attempts that were retried
+	ProcessedItems uint64 // number of tasks in successfully completed chunks
+	ActiveWorkers  int32  // workers currently executing a task function
+	QueueDepth     int64  // chunks waiting for a free worker
+	ChunksInFlight int64  // chunks dispatched to a worker and not yet finished
+
+	ThrottledTime time.Duration // total time task function calls waited for the rate limiter
+
+	Latency    HistogramSnapshot // per-chunk latency distribution
+	Throughput float64           // finished chunks per second over Elapsed
+	Elapsed    time.Duration     // time since the processor was created or reset
+}
+
+// HistogramBucket is a single histogram bucket
+type HistogramBucket struct {
+	UpperBound time.Duration // inclusive upper bound of the bucket
+	Count      uint64        // number of observations in the bucket (not cumulative)
+}
+
+// HistogramSnapshot is a point-in-time copy of a latency histogram
+type HistogramSnapshot struct {
+	Count    uint64            // total number of observations
+	Sum      time.Duration     // sum of all observations
+	Buckets  []HistogramBucket // bucket counts in ascending bound order
+	Overflow uint64            // observations above the largest bucket bound
+
+	P50 time.Duration // estimated median
+	P95 time.Duration // estimated 95th percentile
+	P99 time.Duration // estimated 99th percentile
+}
+
+// Quantile estimates the q-quantile (0 <= q <= 1) by linear interpolation within
+// the bucket that contains it.
+func (h HistogramSnapshot) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+
+	rank := q * float64(h.Count)
+	var (
+		cumulative uint64
+		lower      time.Duration
+	)
+	for _, b := range h.Buckets {
+		if b.Count > 0 && float64(cumulative+b.Count) >= rank {
+			frac := (rank - float64(cumulative)) / float64(b.Count)
+			return lower + time.Duration(frac*float64(b.UpperBound-lower))
+		}
+		cumulative += b.Count
+		lower = b.UpperBound
+	}
+	return lower // falls in the overflow bucket
+}
+
+// histogram is a lock-free latency histogram over latencyBuckets
+type histogram struct {
+	counts   []atomic.Uint64 // one counter per bucket plus the overflow bucket
+	count    atomic.Uint64
+	sumNanos atomic.Int64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]atomic.Uint64, len(latencyBuckets)+1)}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(latencyBuckets) && d > latencyBuckets[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.count.Add(1)
+	h.sumNanos.Add(int64(d))
+}
+
+func (h *histogram) snapshot() HistogramSnapshot {
+	s := HistogramSnapshot{
+		Count:    h.count.Load(),
+		Sum:      time.Duration(h.sumNanos.Load()),
+		Buckets:  make([]HistogramBucket, len(latencyBuckets)),
+		Overflow: h.counts[len(latencyBuckets)].Load(),
+	}
+	for i, bound := range latencyBuckets {
+		s.Buckets[i] = HistogramBucket{UpperBound: bound, Count: h.counts[i].Load()}
+	}
+
+	s.P50, s.P95, s.P99 = s.Quantile(0.50), s.Quantile(0.95), s.Quantile(0.99)
+	return s
+}
+
+func (h *histogram) reset() {
+	for i := range h.counts {
+		h.counts[i].Store(0)
+	}
+	h.count.Store(0)
+	h.sumNanos.Store(0)
+}
+
+// processorStats holds the live counters behind ProcessorMetrics
+type processorStats struct {
+	completedTasks atomic.Uint64
+	errorCount     atomic.Uint64
+	retryCount     atomic.Uint64
+	processedItems atomic.Uint64
+	activeWorkers  atomic.Int32
+	queueDepth     atomic.Int64
+	chunksInFlight atomic.Int64
+	throttledNanos atomic.Int64
+
+	latency *histogram
+
+	mu    sync.Mutex
+	since time.Time // start of the current measurement period
+}
+
+func newProcessorStats() *processorStats {
+	return &processorStats{latency: newHistogram(), since: time.Now()}
+}
+
+func (s *processorStats) snapshot() ProcessorMetrics {
+	s.mu.Lock()
+	elapsed := time.Since(s.since)
+	s.mu.Unlock()
+
+	m := ProcessorMetrics{
+		CompletedTasks: s.completedTasks.Load(),
+		ErrorCount:     s.errorCount.Load(),
+		RetryCount:     s.retryCount.Load(),
+		ProcessedItems: s.processedItems.Load(),
+		ActiveWorkers:  s.activeWorkers.Load(),
+		QueueDepth:     s.queueDepth.Load(),
+		ChunksInFlight: s.chunksInFlight.Load(),
+		ThrottledTime:  time.Duration(s.throttledNanos.Load()),
+		Latency:        s.latency.snapshot(),
+		Elapsed:        elapsed,
+	}
+	if elapsed > 0 {
+		m.Throughput = float64(m.CompletedTasks+m.ErrorCount) / elapsed.Seconds()
+	}
+	return m
+}
+
+// reset clears the counters. Gauges describing work in progress are left untouched.
+func (s *processorStats) reset() {
+	s.completedTasks.Store(0)
+	s.errorCount.Store(0)
+	s.retryCount.Store(0)
+	s.processedItems.Store(0)
+	s.throttledNanos.Store(0)
+	s.latency.reset()
+
+	s.mu.Lock()
+	s.since = time.Now()
+	s.mu.Unlock()
+}
+
+// Snapshot returns a snapshot of the current processor's performance metrics
+func (p *TaskProcessor) Snapshot() ProcessorMetrics { return p.metrics.snapshot() }
+
+// Metrics is Snapshot, kept for compatibility.
+func (p *TaskProcessor) Metrics() ProcessorMetrics { return p.Snapshot() }
+
+// Reset clears the processor's counters and latency histogram and restarts the
+// throughput measurement period. Gauges such as ActiveWorkers are not affected.
+func (p *TaskProcessor) Reset() { p.metrics.reset() }
+
+// MetricsHandler returns an http.Handler serving the processor's metrics in the
+// Prometheus text exposition format under the given namespace.
+func (p *TaskProcessor) MetricsHandler(namespace string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = p.Snapshot().WritePrometheus(w, namespace)
+	})
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format.
+// Metric names are prefixed with namespace followed by an underscore, if not empty.
+func (m ProcessorMetrics) WritePrometheus(w io.Writer, namespace string) error {
+	name := func(s string) string {
+		if namespace == "" {
+			return s
+		}
+		return namespace + "_" + s
+	}
+
+	var b strings.Builder
+	metric := func(metricName, kind, help string, value string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %s\n",
+			metricName, help, metricName, kind, metricName, value)
+	}
+	uint64Str := func(v uint64) string { return strconv.FormatUint(v, 10) }
+	int64Str := func(v int64) string { return strconv.FormatInt(v, 10) }
+	floatStr := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+
+	metric(name("completed_tasks_total"), "counter",
+		"Number of successfully completed task function calls.", uint64Str(m.CompletedTasks))
+	metric(name("errors_total"), "counter",
+		"Number of task function calls that finally failed.", uint64Str(m.ErrorCount))
+	metric(name("retries_total"), "counter",
+		"Number of failed attempts that were retried.", uint64Str(m.RetryCount))
+	metric(name("processed_items_total"), "counter",
+		"Number of tasks in successfully completed chunks.", uint64Str(m.ProcessedItems))
+	metric(name("active_workers"), "gauge",
+		"Workers currently executing a task function.", int64Str(int64(m.ActiveWorkers)))
+	metric(name("queue_depth"), "gauge",
+		"Chunks waiting for a free worker.", int64Str(m.QueueDepth))
+	metric(name("chunks_in_flight"), "gauge",
+		"Chunks dispatched to a worker and not yet finished.", int64Str(m.ChunksInFlight))
+	metric(name("throttled_seconds_total"), "counter",
+		"Total time task function calls waited for the rate limiter.", floatStr(m.ThrottledTime.Seconds()))
+	metric(name("throughput_chunks_per_second"), "gauge",
+		"Finished chunks per second since the metrics were reset.", floatStr(m.Throughput))
+
+	hist := name("chunk_duration_seconds")
+	fmt.Fprintf(&b, "# HELP %s Per-chunk processing latency.\n# TYPE %s histogram\n", hist, hist)
+	var cumulative uint64
+	for _, bucket := range m.Latency.Buckets {
+		cumulative += bucket.Count
+		fmt.Fprintf(&b, "%s_bucket{le=\"%s\"} %d\n", hist, floatStr(bucket.UpperBound.Seconds()), cumulative)
+	}
+	fmt.Fprintf(&b, "%s_bucket{le=\"+Inf\"} %d\n", hist, m.Latency.Count)
+	fmt.Fprintf(&b, "%s_sum %s\n%s_count %d\n", hist, floatStr(m.Latency.Sum.Seconds()), hist, m.Latency.Count)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
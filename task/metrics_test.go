@@ -0,0 +1,99 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistogramSnapshot_Quantile(t *testing.T) {
+	h := newHistogram()
+	for i := 0; i < 90; i++ {
+		h.observe(time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		h.observe(time.Second)
+	}
+
+	s := h.snapshot()
+	if s.Count != 100 || s.Sum != 90*time.Millisecond+10*time.Second {
+		t.Fatalf("Unexpected count/sum: %d/%v", s.Count, s.Sum)
+	}
+	// 1ms falls into the (800µs, 1.6ms] bucket
+	if s.P50 < 800*time.Microsecond || s.P50 > 1600*time.Microsecond {
+		t.Errorf("Expected p50 within the 1ms bucket, got %v", s.P50)
+	}
+	if s.P99 < 800*time.Millisecond || s.P99 > 2*time.Second {
+		t.Errorf("Expected p99 around 1s, got %v", s.P99)
+	}
+
+	h.reset()
+	if s := h.snapshot(); s.Count != 0 || s.P99 != 0 {
+		t.Errorf("Expected empty histogram after reset, got %+v", s)
+	}
+}
+
+func TestTaskProcessor_Metrics(t *testing.T) {
+	processor := NewTaskProcessor(WithMaxWorkerCount(4))
+
+	tasks := make([]any, 100)
+	err := processor.ProcessInChunks(context.Background(), tasks,
+		func(_ context.Context, chunk []any) (any, error) {
+			if len(chunk) == 0 {
+				return nil, errors.New("empty chunk")
+			}
+			time.Sleep(time.Millisecond)
+			return len(chunk), nil
+		})
+	if err != nil {
+		t.Fatalf("ProcessInChunks failed: %v", err)
+	}
+
+	stats := processor.Snapshot()
+	if m := processor.Metrics(); m.CompletedTasks != stats.CompletedTasks || m.Latency.Count != stats.Latency.Count {
+		t.Errorf("Expected Metrics to be Snapshot, got %+v and %+v", m, stats)
+	}
+	if stats.CompletedTasks != 4 || stats.ProcessedItems != 100 {
+		t.Errorf("Expected 4 chunks and 100 items, got %d and %d", stats.CompletedTasks, stats.ProcessedItems)
+	}
+	if stats.QueueDepth != 0 || stats.ChunksInFlight != 0 || stats.ActiveWorkers != 0 {
+		t.Errorf("Expected idle gauges, got %+v", stats)
+	}
+	if stats.Latency.Count != 4 || stats.Latency.P50 < time.Millisecond/2 {
+		t.Errorf("Unexpected latency histogram: %+v", stats.Latency)
+	}
+	if stats.Throughput <= 0 {
+		t.Errorf("Expected positive throughput, got %v", stats.Throughput)
+	}
+
+	processor.Reset()
+	if stats := processor.Snapshot(); stats.CompletedTasks != 0 || stats.Latency.Count != 0 {
+		t.Errorf("Expected metrics to be reset, got %+v", stats)
+	}
+}
+
+func TestProcessorMetrics_WritePrometheus(t *testing.T) {
+	processor := NewTaskProcessor(WithMaxWorkerCount(2))
+	_ = processor.ProcessInChunks(context.Background(), []any{1, 2},
+		func(_ context.Context, _ []any) (any, error) { return nil, nil })
+
+	rec := httptest.NewRecorder()
+	processor.MetricsHandler("ziwi_task").ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE ziwi_task_completed_tasks_total counter",
+		"ziwi_task_completed_tasks_total 2",
+		"ziwi_task_errors_total 0",
+		"ziwi_task_queue_depth 0",
+		`ziwi_task_chunk_duration_seconds_bucket{le="+Inf"} 2`,
+		"ziwi_task_chunk_duration_seconds_count 2",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected exposition to contain %q, got:\n%s", want, body)
+		}
+	}
+}
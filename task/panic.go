@@ -0,0 +1,36 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is the error reported in place of a task's result when the task panics
+type PanicError struct {
+	Value any    // value passed to panic
+	Stack []byte // stack trace of the panicking goroutine
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task panicked: %v\n%s", e.Value, e.Stack)
+}
+
+// Unwrap returns the panic value if it is an error
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// safeCall calls fn and converts a panic into a *PanicError.
+func safeCall[T any](ctx context.Context, fn func(ctx context.Context) (T, error)) (result T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+
+	return fn(ctx)
+}
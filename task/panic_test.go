@@ -0,0 +1,105 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTaskProcessor_RecoversPanics(t *testing.T) {
+	var panics atomic.Int32
+	processor := NewTaskProcessor(
+		WithMaxWorkerCount(2),
+		WithErrorHandler(func(err error) {
+			var panicErr *PanicError
+			if errors.As(err, &panicErr) {
+				panics.Add(1)
+			}
+		}),
+	)
+
+	panicky := func(_ context.Context, chunk []any) (any, error) {
+		if chunk[0] == 0 {
+			panic("boom")
+		}
+		return nil, nil
+	}
+
+	// Running twice proves the worker slots were released after the panic
+	for i := 0; i < 2; i++ {
+		if err := processor.ProcessInChunks(context.Background(), []any{0, 1}, panicky); err != nil {
+			t.Fatalf("ProcessInChunks failed: %v", err)
+		}
+	}
+
+	stats := processor.Metrics()
+	if panics.Load() != 2 || stats.ErrorCount != 2 || stats.CompletedTasks != 2 {
+		t.Errorf("Expected 2 recovered panics and 2 completed chunks, got %d panics and %+v",
+			panics.Load(), stats)
+	}
+}
+
+func TestTaskProcessor_PanicNotRetried(t *testing.T) {
+	var errs []error
+	processor := NewTaskProcessor(
+		WithMaxWorkerCount(1),
+		WithRetry(RetryPolicy{MaxAttempts: 5}),
+		WithErrorHandler(func(err error) { errs = append(errs, err) }),
+	)
+
+	var calls atomic.Int32
+	err := processor.ProcessInChunks(context.Background(), []any{0},
+		func(_ context.Context, _ []any) (any, error) {
+			calls.Add(1)
+			panic("boom")
+		})
+	if err != nil {
+		t.Fatalf("ProcessInChunks failed: %v", err)
+	}
+
+	// The panic is reported once, so WithRePanic fires on the first attempt
+	var panicErr *PanicError
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected a panicking task to run once, ran %d times", n)
+	}
+	if len(errs) != 1 || !errors.As(errs[0], &panicErr) {
+		t.Errorf("Expected a single *PanicError, got %v", errs)
+	}
+	if stats := processor.Metrics(); stats.RetryCount != 0 {
+		t.Errorf("Expected no retries, got %d", stats.RetryCount)
+	}
+}
+
+func TestPanicError(t *testing.T) {
+	errCause := errors.New("cause")
+	_, err := safeCall(context.Background(), func(_ context.Context) (int, error) {
+		panic(errCause)
+	})
+
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("Expected *PanicError, got %v", err)
+	}
+	if !errors.Is(err, errCause) {
+		t.Errorf("Expected PanicError to unwrap to the panic value")
+	}
+	if !strings.Contains(string(panicErr.Stack), "TestPanicError") {
+		t.Errorf("Expected the stack to include the panicking function, got:\n%s", panicErr.Stack)
+	}
+}
+
+func TestPool_RecoversPanics(t *testing.T) {
+	pool := NewPool(WithPoolWorkers(1))
+	defer pool.Stop(context.Background())
+
+	_, err := Submit(context.Background(), pool, func(_ context.Context) (int, error) {
+		panic("boom")
+	}).Await(context.Background())
+
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.Value != "boom" {
+		t.Errorf("Expected *PanicError with value boom, got %v", err)
+	}
+}
@@ -0,0 +1,393 @@
+package task
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Pipeline runs a chain of stages connected by bounded channels.
+//
+// Stages are added with Source, Map, Filter, Batch and Sink, and start running
+// immediately. The first error returned by any stage cancels the whole pipeline, and
+// Wait reports it once every stage has stopped.
+//
+// Map stages run on a TaskProcessor: its worker limit, group scheduling, rate limit,
+// circuit breaker, timeout, retry policy and handlers apply to every value, and its
+// metrics count every value as a processed item. Use WithProcessor to share one.
+//
+//	p := task.NewPipeline(ctx, task.WithBufferSize(64))
+//	lines := task.Source(p, chLines)
+//	rows := task.Map(lines, 8, parse, task.Ordered())
+//	task.Sink(task.Batch(rows, 1000, time.Second), writeCSV)
+//	err := p.Wait()
+type Pipeline struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	buffer int            // capacity of the channel between two stages
+	tp     *TaskProcessor // runs the values of Map stages
+
+	wg      sync.WaitGroup
+	errOnce sync.Once
+	err     error
+}
+
+// PipelineOption defines the functional option type for Pipeline configuration
+type PipelineOption func(*Pipeline)
+
+// WithBufferSize configures the capacity of the channels between stages. A full buffer
+// blocks the upstream stage, which propagates backpressure to the source.
+func WithBufferSize(n int) PipelineOption {
+	return func(p *Pipeline) { p.buffer = n }
+}
+
+// WithProcessor runs the Map stages on tp, which also bounds the number of values
+// being mapped at once across all of them. By default each pipeline has its own
+// processor without a worker limit, leaving each stage bounded by its own workers.
+func WithProcessor(tp *TaskProcessor) PipelineOption {
+	return func(p *Pipeline) { p.tp = tp }
+}
+
+// NewPipeline creates an empty Pipeline bound to ctx
+func NewPipeline(ctx context.Context, options ...PipelineOption) *Pipeline {
+	p := &Pipeline{}
+	for _, option := range options {
+		option(p)
+	}
+	if p.buffer < 0 {
+		p.buffer = 0
+	}
+	if p.tp == nil {
+		p.tp = NewTaskProcessor(WithMaxWorkerCount(math.MaxInt))
+	}
+
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	return p
+}
+
+// Wait blocks until every stage has stopped and returns the first stage error, or the
+// context error if the pipeline was cancelled from outside.
+func (p *Pipeline) Wait() error {
+	p.wg.Wait()
+	defer p.cancel()
+
+	if p.err != nil {
+		return p.err
+	}
+	return p.ctx.Err()
+}
+
+// Processor returns the TaskProcessor running the Map stages, e.g. to read its metrics.
+func (p *Pipeline) Processor() *TaskProcessor {
+	return p.tp
+}
+
+// fail records err as the pipeline error, if it is the first one, and stops every stage.
+func (p *Pipeline) fail(err error) {
+	p.errOnce.Do(func() {
+		p.err = err
+		p.cancel()
+	})
+}
+
+// goStage runs fn as a stage goroutine.
+func (p *Pipeline) goStage(fn func()) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		fn()
+	}()
+}
+
+// Stage is the output of a pipeline stage carrying values of type T
+type Stage[T any] struct {
+	p   *Pipeline
+	out <-chan T
+}
+
+// process runs fn for a single value on a worker slot of the pipeline's processor.
+func process[Out any](p *Pipeline, fn func(ctx context.Context) (Out, error)) (Out, error) {
+	var zero Out
+	tp := p.tp
+	group, priority := groupFromContext(p.ctx)
+
+	tp.metrics.queueDepth.Add(1)
+	err := tp.workerPool.acquire(p.ctx, group, priority)
+	tp.metrics.queueDepth.Add(-1)
+	if err != nil {
+		return zero, err
+	}
+	defer tp.workerPool.release(group)
+
+	tp.metrics.chunksInFlight.Add(1)
+	defer tp.metrics.chunksInFlight.Add(-1)
+
+	result, err := tp.execute(p.ctx, 1, func(ctx context.Context) (any, error) { return fn(ctx) }, nil)
+	if err != nil {
+		return zero, err
+	}
+	out, _ := result.(Out) // a nil interface result is the zero Out
+	return out, nil
+}
+
+// send delivers v downstream unless the pipeline is cancelled first.
+func send[T any](ctx context.Context, ch chan<- T, v T) bool {
+	select {
+	case ch <- v:
+		return true
+
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// StageOption configures a single stage
+type StageOption func(*stageConfig)
+
+type stageConfig struct {
+	ordered bool
+}
+
+// Ordered makes a Map stage emit results in input order. Results that finish early are
+// held back until all earlier ones have been emitted.
+func Ordered() StageOption {
+	return func(c *stageConfig) { c.ordered = true }
+}
+
+// Source starts a pipeline from in. The stage ends when in is closed or the pipeline
+// is cancelled.
+func Source[T any](p *Pipeline, in <-chan T) *Stage[T] {
+	out := make(chan T, p.buffer)
+
+	p.goStage(func() {
+		defer close(out)
+
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok || !send(p.ctx, out, v) {
+					return
+				}
+
+			case <-p.ctx.Done():
+				return
+			}
+		}
+	})
+	return &Stage[T]{p: p, out: out}
+}
+
+// Map applies fn to every value of s using the given number of workers.
+// Output order is unspecified unless the Ordered option is set. A panic in fn fails the
+// pipeline with a *PanicError. Every value is run on the pipeline's TaskProcessor, see
+// Pipeline.
+func Map[In, Out any](s *Stage[In], workers int, fn func(ctx context.Context, v In) (Out, error),
+	options ...StageOption,
+) *Stage[Out] {
+	var cfg stageConfig
+	for _, option := range options {
+		option(&cfg)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	if cfg.ordered {
+		return mapOrdered(s, workers, fn)
+	}
+
+	p := s.p
+	out := make(chan Out, p.buffer)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for range workers {
+		p.goStage(func() {
+			defer wg.Done()
+
+			for v := range s.out {
+				result, err := process(p, func(ctx context.Context) (Out, error) { return fn(ctx, v) })
+				if err != nil {
+					p.fail(err)
+					return
+				}
+				if !send(p.ctx, out, result) {
+					return
+				}
+			}
+		})
+	}
+
+	p.goStage(func() {
+		wg.Wait()
+		close(out)
+	})
+	return &Stage[Out]{p: p, out: out}
+}
+
+// mapOrdered is Map with results emitted in input order.
+func mapOrdered[In, Out any](s *Stage[In], workers int, fn func(ctx context.Context, v In) (Out, error)) *Stage[Out] {
+	type result struct {
+		v  Out
+		ok bool
+	}
+	type job struct {
+		v    In
+		slot chan result
+	}
+
+	p := s.p
+	out := make(chan Out, p.buffer)
+	jobs := make(chan job)
+	slots := make(chan chan result, p.buffer+workers) // results pending in input order
+
+	// Dispatcher: reserve an output slot for every input before handing it to a worker
+	p.goStage(func() {
+		defer close(jobs)
+		defer close(slots)
+
+		for v := range s.out {
+			slot := make(chan result, 1)
+			if !send(p.ctx, slots, slot) || !send(p.ctx, jobs, job{v: v, slot: slot}) {
+				return
+			}
+		}
+	})
+
+	for range workers {
+		p.goStage(func() {
+			for j := range jobs {
+				v, err := process(p, func(ctx context.Context) (Out, error) { return fn(ctx, j.v) })
+				if err != nil {
+					p.fail(err)
+				}
+				j.slot <- result{v: v, ok: err == nil}
+			}
+		})
+	}
+
+	// Collector: emit results in the order their slots were reserved
+	p.goStage(func() {
+		defer close(out)
+
+		for slot := range slots {
+			var r result
+			select {
+			case r = <-slot:
+			case <-p.ctx.Done():
+				return
+			}
+			if !r.ok || !send(p.ctx, out, r.v) {
+				return
+			}
+		}
+	})
+	return &Stage[Out]{p: p, out: out}
+}
+
+// Filter forwards the values of s for which keep returns true.
+func Filter[T any](s *Stage[T], keep func(ctx context.Context, v T) (bool, error)) *Stage[T] {
+	p := s.p
+	out := make(chan T, p.buffer)
+
+	p.goStage(func() {
+		defer close(out)
+
+		for v := range s.out {
+			ok, err := safeCall(p.ctx, func(ctx context.Context) (bool, error) { return keep(ctx, v) })
+			if err != nil {
+				p.fail(err)
+				return
+			}
+			if ok && !send(p.ctx, out, v) {
+				return
+			}
+		}
+	})
+	return &Stage[T]{p: p, out: out}
+}
+
+// Batch groups the values of s into slices of up to size values. A partial batch is
+// emitted once timeout has elapsed since its first value, if timeout is positive, and
+// when s is exhausted.
+func Batch[T any](s *Stage[T], size int, timeout time.Duration) *Stage[[]T] {
+	if size < 1 {
+		size = 1
+	}
+
+	p := s.p
+	out := make(chan []T, p.buffer)
+
+	p.goStage(func() {
+		defer close(out)
+
+		var (
+			batch []T
+			timer *time.Timer
+			tick  <-chan time.Time // nil while the batch is empty
+		)
+		flush := func() bool {
+			if timer != nil {
+				timer.Stop()
+				tick = nil
+			}
+			if len(batch) == 0 {
+				return true
+			}
+			full := batch
+			batch = nil
+			return send(p.ctx, out, full)
+		}
+
+		for {
+			select {
+			case v, ok := <-s.out:
+				if !ok {
+					flush()
+					return
+				}
+
+				batch = append(batch, v)
+				if len(batch) == 1 && timeout > 0 {
+					if timer == nil {
+						timer = time.NewTimer(timeout)
+					} else {
+						timer.Reset(timeout)
+					}
+					tick = timer.C
+				}
+				if len(batch) >= size && !flush() {
+					return
+				}
+
+			case <-tick:
+				tick = nil
+				if !flush() {
+					return
+				}
+
+			case <-p.ctx.Done():
+				return
+			}
+		}
+	})
+	return &Stage[[]T]{p: p, out: out}
+}
+
+// Sink consumes the values of s with fn. It ends the pipeline: call Wait to know when
+// every value has been consumed.
+func Sink[T any](s *Stage[T], fn func(ctx context.Context, v T) error) {
+	p := s.p
+
+	p.goStage(func() {
+		for v := range s.out {
+			if _, err := safeCall(p.ctx, func(ctx context.Context) (struct{}, error) {
+				return struct{}{}, fn(ctx, v)
+			}); err != nil {
+				p.fail(err)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,196 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	in := make(chan int)
+	go func() {
+		defer close(in)
+		for i := 1; i <= 100; i++ {
+			in <- i
+		}
+	}()
+
+	p := NewPipeline(context.Background(), WithBufferSize(4))
+	squares := Map(Source(p, in), 8, func(_ context.Context, v int) (int, error) {
+		time.Sleep(time.Duration(v%3) * time.Millisecond) // finish out of order
+		return v * v, nil
+	}, Ordered())
+	even := Filter(squares, func(_ context.Context, v int) (bool, error) { return v%2 == 0, nil })
+	strs := Map(even, 1, func(_ context.Context, v int) (string, error) { return strconv.Itoa(v), nil })
+
+	var batches [][]string
+	Sink(Batch(strs, 20, time.Second), func(_ context.Context, batch []string) error {
+		batches = append(batches, batch)
+		return nil
+	})
+
+	if err := p.Wait(); err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+
+	var got []string
+	for _, batch := range batches {
+		got = append(got, batch...)
+	}
+	want := make([]string, 0, 50)
+	for i := 2; i <= 100; i += 2 {
+		want = append(want, strconv.Itoa(i*i))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected ordered even squares, got %v", got)
+	}
+	if len(batches) != 3 || len(batches[2]) != 10 {
+		t.Errorf("Expected batches of 20, 20 and 10, got %d batches", len(batches))
+	}
+}
+
+func TestPipeline_ErrorCancelsStages(t *testing.T) {
+	in := make(chan int)
+	stop := make(chan struct{})
+	go func() {
+		defer close(in)
+		for i := 0; ; i++ {
+			select {
+			case in <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	defer close(stop)
+
+	errStage := errors.New("stage failed")
+	p := NewPipeline(context.Background())
+	mapped := Map(Source(p, in), 4, func(_ context.Context, v int) (int, error) {
+		if v == 10 {
+			return 0, errStage
+		}
+		return v, nil
+	})
+	Sink(mapped, func(_ context.Context, _ int) error { return nil })
+
+	if err := p.Wait(); !errors.Is(err, errStage) {
+		t.Errorf("Expected stage error, got %v", err)
+	}
+}
+
+func TestPipeline_BatchTimeout(t *testing.T) {
+	in := make(chan int)
+	p := NewPipeline(context.Background())
+
+	got := make(chan []int, 1)
+	Sink(Batch(Source(p, in), 10, 10*time.Millisecond), func(_ context.Context, batch []int) error {
+		got <- batch
+		return nil
+	})
+
+	in <- 1
+	in <- 2
+	select {
+	case batch := <-got:
+		if !reflect.DeepEqual(batch, []int{1, 2}) {
+			t.Errorf("Expected partial batch [1 2], got %v", batch)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected partial batch to be flushed after the timeout")
+	}
+
+	close(in)
+	if err := p.Wait(); err != nil {
+		t.Errorf("Pipeline failed: %v", err)
+	}
+}
+
+func TestPipeline_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := NewPipeline(ctx)
+	Sink(Source(p, make(chan int)), func(_ context.Context, _ int) error { return nil })
+
+	cancel()
+	if err := p.Wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestPipeline_Processor(t *testing.T) {
+	in := make(chan int)
+	go func() {
+		defer close(in)
+		for i := range 50 {
+			in <- i
+		}
+	}()
+
+	var (
+		mu            sync.Mutex
+		running, peak int
+		attempts      = make(map[int]int)
+	)
+	tp := NewTaskProcessor(WithMaxWorkerCount(2), WithRetry(RetryPolicy{MaxAttempts: 2}))
+	p := NewPipeline(context.Background(), WithProcessor(tp))
+
+	// The processor's worker limit applies across both stages, and flaky values are retried
+	work := func(_ context.Context, v int) (int, error) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		running--
+		attempts[v]++
+		if v%10 == 0 && attempts[v] == 1 {
+			return 0, errors.New("transient")
+		}
+		return v, nil
+	}
+	doubled := Map(Source(p, in), 8, work)
+	Sink(Map(doubled, 8, work), func(_ context.Context, _ int) error { return nil })
+
+	if err := p.Wait(); err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	if peak > 2 {
+		t.Errorf("Expected at most 2 values mapped at once, got %d", peak)
+	}
+	if p.Processor() != tp {
+		t.Errorf("Expected the pipeline to run on the given processor")
+	}
+	stats := tp.Snapshot()
+	if stats.CompletedTasks != 100 || stats.ProcessedItems != 100 || stats.RetryCount != 5 {
+		t.Errorf("Expected 100 values mapped with 5 retries, got %+v", stats)
+	}
+	if stats.QueueDepth != 0 || stats.ChunksInFlight != 0 || stats.ActiveWorkers != 0 {
+		t.Errorf("Expected idle gauges, got %+v", stats)
+	}
+}
+
+func TestPipeline_MapPanic(t *testing.T) {
+	in := make(chan int, 1)
+	in <- 1
+	close(in)
+
+	tp := NewTaskProcessor(WithRetry(RetryPolicy{MaxAttempts: 3}))
+	p := NewPipeline(context.Background(), WithProcessor(tp))
+	Sink(Map(Source(p, in), 1, func(_ context.Context, _ int) (int, error) { panic("boom") }),
+		func(_ context.Context, _ int) error { return nil })
+
+	var panicErr *PanicError
+	if err := p.Wait(); !errors.As(err, &panicErr) {
+		t.Errorf("Expected a *PanicError, got %v", err)
+	}
+	if stats := tp.Snapshot(); stats.ErrorCount != 1 || stats.RetryCount != 0 {
+		t.Errorf("Expected a single unretried failure, got %+v", stats)
+	}
+}
@@ -0,0 +1,359 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrPoolStopped is returned when submitting to, or awaiting a task of, a stopped pool.
+	ErrPoolStopped = errors.New("task: pool stopped")
+	// ErrPoolFull is returned by Submit when the queue is full and the policy is RejectPolicyAbort.
+	ErrPoolFull = errors.New("task: pool queue full")
+)
+
+// RejectPolicy decides what Submit does when the pool queue is full
+type RejectPolicy int
+
+const (
+	// RejectPolicyBlock blocks the submitter until queue space is available (backpressure)
+	RejectPolicyBlock RejectPolicy = iota
+	// RejectPolicyAbort rejects the task immediately with ErrPoolFull
+	RejectPolicyAbort
+	// RejectPolicyCallerRuns runs the task in the submitting goroutine
+	RejectPolicyCallerRuns
+)
+
+// PoolMetrics is a point-in-time snapshot of a Pool's metrics
+type PoolMetrics struct {
+	Workers        int    // current number of worker goroutines
+	ActiveWorkers  int32  // workers currently executing a task
+	QueueDepth     int    // tasks waiting in the queue
+	SubmittedTasks uint64 // tasks accepted by Submit
+	CompletedTasks uint64 // tasks finished without error
+	ErrorCount     uint64 // tasks finished with an error
+	RejectedTasks  uint64 // tasks rejected because the queue was full or the pool stopped
+}
+
+// poolJob is a queued unit of work. run executes the task, fail resolves it without running.
+type poolJob struct {
+	run  func(ctx context.Context) error
+	fail func(err error)
+}
+
+// Pool is a long-lived worker pool with a bounded queue
+type Pool struct {
+	queue  chan *poolJob // bounded task queue
+	policy RejectPolicy  // behavior when the queue is full
+
+	mu       sync.Mutex
+	target   int           // desired number of workers
+	workers  int           // running number of workers
+	resized  chan struct{} // closed to wake idle workers after a resize
+	closed   bool          // no more submissions accepted
+	workerWg sync.WaitGroup
+	submitWg sync.WaitGroup // submissions in progress
+
+	ctx    context.Context // cancelled by Stop to abort running tasks
+	cancel context.CancelFunc
+
+	activeWorkers  int32
+	submittedTasks uint64
+	completedTasks uint64
+	errorCount     uint64
+	rejectedTasks  uint64
+}
+
+// PoolOption defines the functional option type for Pool configuration
+type PoolOption func(*poolConfig)
+
+type poolConfig struct {
+	workers   int
+	queueSize int
+	policy    RejectPolicy
+}
+
+// WithPoolWorkers configures the initial number of pool workers
+func WithPoolWorkers(n int) PoolOption {
+	return func(c *poolConfig) { c.workers = n }
+}
+
+// WithQueueSize configures the capacity of the pool queue
+func WithQueueSize(n int) PoolOption {
+	return func(c *poolConfig) { c.queueSize = n }
+}
+
+// WithRejectPolicy configures the behavior of Submit when the pool queue is full
+func WithRejectPolicy(policy RejectPolicy) PoolOption {
+	return func(c *poolConfig) { c.policy = policy }
+}
+
+// NewPool creates a new Pool and starts its workers.
+// By default it runs one worker per logical CPU with a queue twice that size.
+func NewPool(options ...PoolOption) *Pool {
+	cfg := poolConfig{workers: runtime.GOMAXPROCS(0)}
+	for _, option := range options {
+		option(&cfg)
+	}
+	if cfg.workers < 1 {
+		cfg.workers = 1
+	}
+	if cfg.queueSize <= 0 {
+		cfg.queueSize = 2 * cfg.workers
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		queue:   make(chan *poolJob, cfg.queueSize),
+		policy:  cfg.policy,
+		resized: make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	p.Resize(cfg.workers)
+	return p
+}
+
+// Resize changes the number of workers at runtime. Extra workers exit once they
+// finish their current task.
+func (p *Pool) Resize(n int) {
+	if n < 1 {
+		n = 1
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return
+	}
+
+	p.target = n
+	close(p.resized) // wake idle workers so surplus ones can exit
+	p.resized = make(chan struct{})
+
+	for p.workers < p.target {
+		p.workers++
+		p.workerWg.Add(1)
+		go p.worker()
+	}
+}
+
+// worker pulls jobs from the queue until the pool shrinks or the queue is closed.
+func (p *Pool) worker() {
+	defer p.workerWg.Done()
+
+	for {
+		p.mu.Lock()
+		if p.workers > p.target {
+			p.workers--
+			p.mu.Unlock()
+			return
+		}
+		resized := p.resized
+		p.mu.Unlock()
+
+		select {
+		case job, ok := <-p.queue:
+			if !ok {
+				p.mu.Lock()
+				p.workers--
+				p.mu.Unlock()
+				return
+			}
+			p.execute(job)
+
+		case <-resized:
+		}
+	}
+}
+
+// execute runs a job unless the pool has been stopped.
+func (p *Pool) execute(job *poolJob) {
+	if p.ctx.Err() != nil {
+		job.fail(ErrPoolStopped)
+		return
+	}
+
+	atomic.AddInt32(&p.activeWorkers, 1)
+	defer atomic.AddInt32(&p.activeWorkers, -1)
+
+	if err := job.run(p.ctx); err != nil {
+		atomic.AddUint64(&p.errorCount, 1)
+		return
+	}
+	atomic.AddUint64(&p.completedTasks, 1)
+}
+
+// enqueue hands a job to the pool according to the reject policy.
+func (p *Pool) enqueue(ctx context.Context, job *poolJob) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		atomic.AddUint64(&p.rejectedTasks, 1)
+		return ErrPoolStopped
+	}
+	p.submitWg.Add(1)
+	p.mu.Unlock()
+	defer p.submitWg.Done()
+
+	select {
+	case p.queue <- job:
+		atomic.AddUint64(&p.submittedTasks, 1)
+		return nil
+	default:
+	}
+
+	switch p.policy {
+	case RejectPolicyAbort:
+		atomic.AddUint64(&p.rejectedTasks, 1)
+		return ErrPoolFull
+
+	case RejectPolicyCallerRuns:
+		atomic.AddUint64(&p.submittedTasks, 1)
+		p.execute(job)
+		return nil
+	}
+
+	select {
+	case p.queue <- job:
+		atomic.AddUint64(&p.submittedTasks, 1)
+		return nil
+
+	case <-ctx.Done():
+		atomic.AddUint64(&p.rejectedTasks, 1)
+		return ctx.Err()
+
+	case <-p.ctx.Done():
+		atomic.AddUint64(&p.rejectedTasks, 1)
+		return ErrPoolStopped
+	}
+}
+
+// Drain stops accepting new tasks and waits until every queued and running task has
+// finished, or ctx is done.
+func (p *Pool) Drain(ctx context.Context) error {
+	return p.shutdown(ctx, false)
+}
+
+// Stop stops accepting new tasks, cancels the context of running tasks, resolves queued
+// tasks with ErrPoolStopped and waits for the workers to exit, or ctx to be done.
+func (p *Pool) Stop(ctx context.Context) error {
+	return p.shutdown(ctx, true)
+}
+
+func (p *Pool) shutdown(ctx context.Context, abort bool) error {
+	p.mu.Lock()
+	first := !p.closed
+	p.closed = true
+	p.mu.Unlock()
+
+	if abort {
+		p.cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		if first {
+			p.submitWg.Wait() // no sender may still be blocked on the queue
+			close(p.queue)
+		}
+		p.workerWg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		if !abort {
+			p.cancel()
+		}
+		return nil
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Metrics returns the current pool's performance metrics
+func (p *Pool) Metrics() PoolMetrics {
+	p.mu.Lock()
+	workers := p.workers
+	p.mu.Unlock()
+
+	return PoolMetrics{
+		Workers:        workers,
+		ActiveWorkers:  atomic.LoadInt32(&p.activeWorkers),
+		QueueDepth:     len(p.queue),
+		SubmittedTasks: atomic.LoadUint64(&p.submittedTasks),
+		CompletedTasks: atomic.LoadUint64(&p.completedTasks),
+		ErrorCount:     atomic.LoadUint64(&p.errorCount),
+		RejectedTasks:  atomic.LoadUint64(&p.rejectedTasks),
+	}
+}
+
+// Future is the pending result of a task submitted to a Pool
+type Future[T any] struct {
+	done   chan struct{}
+	result T
+	err    error
+}
+
+func newFuture[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
+}
+
+func (f *Future[T]) resolve(result T, err error) {
+	f.result, f.err = result, err
+	close(f.done)
+}
+
+// Done returns a channel that is closed once the task has finished.
+func (f *Future[T]) Done() <-chan struct{} { return f.done }
+
+// Await waits for the task to finish and returns its result, or ctx.Err() if ctx is
+// done first. Cancelling ctx does not cancel the task itself.
+func (f *Future[T]) Await(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.result, f.err
+
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Submit queues fn on the pool and returns a Future for its result.
+//
+// ctx bounds both the wait for queue space and the task itself: fn receives a context
+// that is cancelled when ctx is done or the pool is stopped. If the task cannot be
+// queued the returned Future is already resolved with the error. A panic in fn resolves
+// the Future with a *PanicError.
+func Submit[T any](ctx context.Context, p *Pool, fn func(ctx context.Context) (T, error)) *Future[T] {
+	future := newFuture[T]()
+
+	job := &poolJob{
+		run: func(poolCtx context.Context) error {
+			taskCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			stop := context.AfterFunc(poolCtx, cancel)
+			defer stop()
+
+			result, err := safeCall(taskCtx, fn)
+			future.resolve(result, err)
+			return err
+		},
+		fail: func(err error) {
+			var zero T
+			future.resolve(zero, err)
+		},
+	}
+
+	if err := p.enqueue(ctx, job); err != nil {
+		job.fail(err)
+	}
+	return future
+}
@@ -0,0 +1,156 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPool_SubmitAwait(t *testing.T) {
+	pool := NewPool(WithPoolWorkers(4))
+	defer pool.Stop(context.Background())
+
+	futures := make([]*Future[int], 0, 100)
+	for i := 0; i < 100; i++ {
+		futures = append(futures, Submit(context.Background(), pool,
+			func(_ context.Context) (int, error) { return i * i, nil }))
+	}
+
+	for i, f := range futures {
+		got, err := f.Await(context.Background())
+		if err != nil {
+			t.Fatalf("Await failed: %v", err)
+		}
+		if got != i*i {
+			t.Errorf("Expected %d, got %d", i*i, got)
+		}
+	}
+
+	if stats := pool.Metrics(); stats.CompletedTasks != 100 {
+		t.Errorf("Expected 100 completed tasks, got %d", stats.CompletedTasks)
+	}
+}
+
+func TestPool_RejectPolicyAbort(t *testing.T) {
+	pool := NewPool(WithPoolWorkers(1), WithQueueSize(1), WithRejectPolicy(RejectPolicyAbort))
+	defer pool.Stop(context.Background())
+
+	release := make(chan struct{})
+	block := func(_ context.Context) (struct{}, error) {
+		<-release
+		return struct{}{}, nil
+	}
+
+	started := make(chan struct{})
+	running := Submit(context.Background(), pool, func(ctx context.Context) (struct{}, error) {
+		close(started)
+		return block(ctx)
+	})
+	<-started
+	queued := Submit(context.Background(), pool, block)
+
+	_, err := Submit(context.Background(), pool, block).Await(context.Background())
+	if !errors.Is(err, ErrPoolFull) {
+		t.Errorf("Expected ErrPoolFull, got %v", err)
+	}
+
+	close(release)
+	for _, f := range []*Future[struct{}]{running, queued} {
+		if _, err := f.Await(context.Background()); err != nil {
+			t.Errorf("Await failed: %v", err)
+		}
+	}
+	if stats := pool.Metrics(); stats.RejectedTasks != 1 {
+		t.Errorf("Expected 1 rejected task, got %d", stats.RejectedTasks)
+	}
+}
+
+func TestPool_Backpressure(t *testing.T) {
+	pool := NewPool(WithPoolWorkers(1), WithQueueSize(1))
+	defer pool.Stop(context.Background())
+
+	release := make(chan struct{})
+	defer close(release)
+	block := func(_ context.Context) (int, error) {
+		<-release
+		return 0, nil
+	}
+
+	Submit(context.Background(), pool, block)
+	Submit(context.Background(), pool, block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := Submit(ctx, pool, block).Await(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected submit to block until deadline, got %v", err)
+	}
+}
+
+func TestPool_Resize(t *testing.T) {
+	pool := NewPool(WithPoolWorkers(1))
+	defer pool.Stop(context.Background())
+
+	pool.Resize(8)
+	if n := pool.Metrics().Workers; n != 8 {
+		t.Errorf("Expected 8 workers, got %d", n)
+	}
+
+	pool.Resize(2)
+	deadline := time.Now().Add(time.Second)
+	for pool.Metrics().Workers != 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := pool.Metrics().Workers; n != 2 {
+		t.Errorf("Expected 2 workers after shrinking, got %d", n)
+	}
+}
+
+func TestPool_DrainAndStop(t *testing.T) {
+	pool := NewPool(WithPoolWorkers(2))
+
+	var finished atomic.Int32
+	for i := 0; i < 10; i++ {
+		Submit(context.Background(), pool, func(_ context.Context) (int, error) {
+			time.Sleep(time.Millisecond)
+			finished.Add(1)
+			return 0, nil
+		})
+	}
+
+	if err := pool.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+	if n := finished.Load(); n != 10 {
+		t.Errorf("Expected 10 finished tasks after drain, got %d", n)
+	}
+
+	_, err := Submit(context.Background(), pool,
+		func(_ context.Context) (int, error) { return 0, nil }).Await(context.Background())
+	if !errors.Is(err, ErrPoolStopped) {
+		t.Errorf("Expected ErrPoolStopped after drain, got %v", err)
+	}
+
+	pool = NewPool(WithPoolWorkers(1))
+	started := make(chan struct{})
+	running := Submit(context.Background(), pool, func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	<-started
+	queued := Submit(context.Background(), pool,
+		func(_ context.Context) (int, error) { return 1, nil })
+
+	if err := pool.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if _, err := running.Await(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected running task to be cancelled, got %v", err)
+	}
+	if _, err := queued.Await(context.Background()); !errors.Is(err, ErrPoolStopped) {
+		t.Errorf("Expected queued task to fail with ErrPoolStopped, got %v", err)
+	}
+}
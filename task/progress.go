@@ -0,0 +1,172 @@
+package task
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kydance/ziwi/log"
+)
+
+// Progress is a progress report of a ProcessInChunks run
+type Progress struct {
+	DoneTasks   int           // tasks in finished chunks, failed or not
+	TotalTasks  int           // tasks passed to ProcessInChunks
+	DoneChunks  int           // finished chunks, failed or not
+	TotalChunks int           // chunks the tasks were split into
+	Errors      int           // failed chunks
+	Rate        float64       // finished tasks per second
+	Elapsed     time.Duration // time since the run started
+	ETA         time.Duration // estimated remaining time, zero until a rate is known
+	Final       bool          // last report of the run
+}
+
+// Percent returns the share of finished tasks in [0, 100].
+func (p Progress) Percent() float64 {
+	if p.TotalTasks == 0 {
+		return 100
+	}
+	return 100 * float64(p.DoneTasks) / float64(p.TotalTasks)
+}
+
+// progressTracker counts finished chunks of a single run and reports them periodically.
+// A nil tracker is valid and does nothing.
+type progressTracker struct {
+	report      func(Progress)
+	start       time.Time
+	totalTasks  int
+	totalChunks int
+
+	doneTasks  atomic.Int64
+	doneChunks atomic.Int64
+	errors     atomic.Int64
+
+	quit chan struct{} // closed to request the final report
+	done chan struct{} // closed after the final report
+}
+
+// startProgress starts reporting the progress of a run, if a reporter is configured.
+func (p *TaskProcessor) startProgress(totalTasks, totalChunks int) *progressTracker {
+	if p.progress == nil {
+		return nil
+	}
+
+	t := &progressTracker{
+		report:      p.progress,
+		start:       time.Now(),
+		totalTasks:  totalTasks,
+		totalChunks: totalChunks,
+		quit:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go t.loop(p.progressInterval)
+	return t
+}
+
+func (t *progressTracker) loop(interval time.Duration) {
+	defer close(t.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.report(t.snapshot(false))
+		case <-t.quit:
+			t.report(t.snapshot(true))
+			return
+		}
+	}
+}
+
+// chunkDone records a finished chunk of size tasks.
+func (t *progressTracker) chunkDone(size int, failed bool) {
+	if t == nil {
+		return
+	}
+
+	t.doneTasks.Add(int64(size))
+	t.doneChunks.Add(1)
+	if failed {
+		t.errors.Add(1)
+	}
+}
+
+// stop delivers the final report and waits for it.
+func (t *progressTracker) stop() {
+	if t == nil {
+		return
+	}
+
+	close(t.quit)
+	<-t.done
+}
+
+func (t *progressTracker) snapshot(final bool) Progress {
+	p := Progress{
+		DoneTasks:   int(t.doneTasks.Load()),
+		TotalTasks:  t.totalTasks,
+		DoneChunks:  int(t.doneChunks.Load()),
+		TotalChunks: t.totalChunks,
+		Errors:      int(t.errors.Load()),
+		Elapsed:     time.Since(t.start),
+		Final:       final,
+	}
+
+	if secs := p.Elapsed.Seconds(); secs > 0 {
+		p.Rate = float64(p.DoneTasks) / secs
+	}
+	if p.Rate > 0 {
+		p.ETA = time.Duration(float64(p.TotalTasks-p.DoneTasks) / p.Rate * float64(time.Second))
+	}
+	return p
+}
+
+// NewProgressBar returns a progress reporter that draws a single-line progress bar on w,
+// typically os.Stderr, redrawing it in place on every report. Use it with WithProgress.
+func NewProgressBar(w io.Writer) func(Progress) {
+	const width = 30
+
+	return func(p Progress) {
+		filled := min(int(p.Percent()/100*width), width)
+		bar := strings.Repeat("=", filled)
+		if filled < width {
+			bar += ">" + strings.Repeat(" ", width-filled-1)
+		}
+
+		line := fmt.Sprintf("\r[%s] %5.1f%% %d/%d tasks  %d errors  %.1f/s",
+			bar, p.Percent(), p.DoneTasks, p.TotalTasks, p.Errors, p.Rate)
+		if p.Final {
+			line += fmt.Sprintf("  done in %v", p.Elapsed.Round(time.Millisecond))
+		} else if p.ETA > 0 {
+			line += fmt.Sprintf("  ETA %v", p.ETA.Round(time.Second))
+		}
+
+		// Clear what is left of a longer previous line
+		line += "\x1b[K"
+		if p.Final {
+			line += "\n"
+		}
+		_, _ = io.WriteString(w, line)
+	}
+}
+
+// LogProgress is a progress reporter that logs every report through the global ziwi
+// logger. Use it with WithProgress.
+func LogProgress(p Progress) {
+	log.Infow("Processing progress",
+		"done_tasks", p.DoneTasks,
+		"total_tasks", p.TotalTasks,
+		"done_chunks", p.DoneChunks,
+		"total_chunks", p.TotalChunks,
+		"errors", p.Errors,
+		"percent", fmt.Sprintf("%.1f", p.Percent()),
+		"rate", fmt.Sprintf("%.1f/s", p.Rate),
+		"elapsed", p.Elapsed.Round(time.Millisecond),
+		"eta", p.ETA.Round(time.Second),
+		"final", p.Final,
+	)
+}
@@ -0,0 +1,95 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTaskProcessor_Progress(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		reports []Progress
+	)
+	processor := NewTaskProcessor(
+		WithMaxWorkerCount(2),
+		WithChunkStrategy(FixedChunkSize(5)),
+		WithProgressInterval(5*time.Millisecond),
+		WithProgress(func(p Progress) {
+			mu.Lock()
+			reports = append(reports, p)
+			mu.Unlock()
+		}),
+	)
+
+	err := processor.ProcessInChunks(context.Background(), make([]any, 40),
+		func(context.Context, []any) (any, error) {
+			time.Sleep(5 * time.Millisecond)
+			return nil, nil
+		})
+	if err != nil {
+		t.Fatalf("ProcessInChunks failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reports) < 2 {
+		t.Fatalf("Expected periodic reports and a final one, got %d", len(reports))
+	}
+	for i := 1; i < len(reports); i++ {
+		if reports[i].DoneTasks < reports[i-1].DoneTasks {
+			t.Errorf("Expected progress to be monotonic, got %d after %d", reports[i].DoneTasks, reports[i-1].DoneTasks)
+		}
+	}
+
+	final := reports[len(reports)-1]
+	if !final.Final || final.DoneTasks != 40 || final.TotalTasks != 40 ||
+		final.DoneChunks != 8 || final.TotalChunks != 8 || final.Percent() != 100 {
+		t.Errorf("Unexpected final report %+v", final)
+	}
+	if final.Rate <= 0 {
+		t.Errorf("Expected a positive rate, got %v", final.Rate)
+	}
+}
+
+func TestTaskProcessor_ProgressDynamic(t *testing.T) {
+	var final Progress
+	processor := NewTaskProcessor(
+		WithMaxWorkerCount(3),
+		WithChunkStrategy(DynamicChunks(4)),
+		WithProgress(func(p Progress) { final = p }),
+	)
+
+	err := processor.ProcessInChunks(context.Background(), make([]any, 10),
+		func(context.Context, []any) (any, error) { return nil, errors.New("boom") })
+	if err != nil {
+		t.Fatalf("ProcessInChunks failed: %v", err)
+	}
+	if !final.Final || final.DoneTasks != 10 || final.TotalChunks != 3 || final.Errors != 3 {
+		t.Errorf("Unexpected final report %+v", final)
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	var buf bytes.Buffer
+	bar := NewProgressBar(&buf)
+
+	bar(Progress{DoneTasks: 50, TotalTasks: 100, Rate: 10, ETA: 5 * time.Second})
+	if out := buf.String(); !strings.HasPrefix(out, "\r[===============>") ||
+		!strings.Contains(out, " 50.0% 50/100 tasks") || !strings.Contains(out, "ETA 5s") {
+		t.Errorf("Unexpected progress bar %q", out)
+	}
+
+	buf.Reset()
+	bar(Progress{DoneTasks: 100, TotalTasks: 100, Errors: 2, Elapsed: time.Second, Final: true})
+	if out := buf.String(); !strings.Contains(out, "[==============================]") ||
+		!strings.Contains(out, "2 errors") || !strings.HasSuffix(out, "\n") {
+		t.Errorf("Unexpected final progress bar %q", out)
+	}
+
+	LogProgress(Progress{DoneTasks: 1, TotalTasks: 2})
+}
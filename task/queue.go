@@ -0,0 +1,684 @@
+package task
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kydance/ziwi/fileutil"
+	"github.com/kydance/ziwi/log"
+)
+
+var (
+	// ErrQueueClosed is returned by the operations of a closed DurableQueue
+	ErrQueueClosed = errors.New("task: queue closed")
+	// ErrUnknownMessage is returned when acknowledging a message the queue does not hold
+	// in flight
+	ErrUnknownMessage = errors.New("task: unknown message")
+	// ErrQueueCorrupt is returned when the write-ahead log cannot be replayed
+	ErrQueueCorrupt = errors.New("task: queue log corrupt")
+)
+
+const (
+	walFile    = "queue.wal"
+	walTmpFile = "queue.wal.tmp"
+)
+
+// QueueMessage is a message delivered by a DurableQueue
+type QueueMessage struct {
+	ID         uint64
+	Payload    []byte
+	EnqueuedAt time.Time
+	Deliveries int    // number of times the message has been delivered, including this one
+	LastError  string // error of the last failed delivery, if any
+}
+
+// QueueStats is a point-in-time snapshot of a DurableQueue
+type QueueStats struct {
+	Ready    int // messages waiting to be delivered
+	InFlight int // messages delivered but not acknowledged yet
+	Dead     int // messages moved to the dead-letter queue
+}
+
+// QueueOption defines the functional option type for DurableQueue configuration
+type QueueOption func(*DurableQueue)
+
+// WithVisibilityTimeout configures how long a delivered message stays invisible before
+// it is delivered again if it was not acknowledged, 30 seconds by default
+func WithVisibilityTimeout(d time.Duration) QueueOption {
+	return func(q *DurableQueue) { q.visibility = d }
+}
+
+// WithMaxDeliveries configures how many times a message is delivered before it is moved
+// to the dead-letter queue, 5 by default
+func WithMaxDeliveries(n int) QueueOption {
+	return func(q *DurableQueue) { q.maxDeliveries = n }
+}
+
+// WithCompactThreshold configures after how many log records the write-ahead log is
+// compacted, provided most of them are obsolete, 1024 by default
+func WithCompactThreshold(n int) QueueOption {
+	return func(q *DurableQueue) { q.compactThreshold = n }
+}
+
+// WithSyncWrites configures whether every log record is flushed to disk before the
+// operation returns, true by default. Disabling it trades durability for throughput.
+func WithSyncWrites(enabled bool) QueueOption {
+	return func(q *DurableQueue) { q.syncWrites = enabled }
+}
+
+// WithQueueClock configures the clock used for visibility timeouts
+func WithQueueClock(clock Clock) QueueOption {
+	return func(q *DurableQueue) { q.clock = clock }
+}
+
+type messageState int
+
+const (
+	messageReady messageState = iota
+	messageInFlight
+	messageDead
+)
+
+type queueEntry struct {
+	msg      QueueMessage
+	state    messageState
+	deadline time.Time // visibility deadline of an in-flight message
+}
+
+// walRecord is a line of the write-ahead log
+type walRecord struct {
+	Op         string    `json:"op"`
+	ID         uint64    `json:"id"`
+	Payload    []byte    `json:"payload,omitempty"`
+	At         time.Time `json:"at,omitzero"`
+	Deliveries int       `json:"deliveries,omitempty"`
+	Err        string    `json:"err,omitempty"`
+}
+
+const (
+	opSequence = "seq"     // highest ID ever assigned, written by compaction
+	opEnqueue  = "enq"     // a new message, or a live message rewritten by compaction
+	opDeliver  = "dlv"     // the message was delivered once more
+	opAck      = "ack"     // the message was processed and removed
+	opNack     = "nack"    // the delivery failed, the message is ready again
+	opDead     = "dlq"     // the message was moved to the dead-letter queue
+	opRedrive  = "redrive" // the message was moved back from the dead-letter queue
+)
+
+// DurableQueue is a persistent FIFO queue with at-least-once delivery.
+//
+// Every state change is appended to a write-ahead log in a local directory and replayed
+// on open, so messages survive restarts and crashes. A delivered message must be
+// acknowledged with Ack; if it is not acknowledged within the visibility timeout, or is
+// rejected with Nack, it is delivered again. Messages delivered too many times are moved
+// to a dead-letter queue. Messages that were in flight when the process stopped are
+// delivered again after a restart.
+type DurableQueue struct {
+	dir              string
+	visibility       time.Duration
+	maxDeliveries    int
+	compactThreshold int
+	syncWrites       bool
+	clock            Clock
+
+	mu      sync.Mutex
+	wal     *os.File
+	records int // records in the log since the last compaction
+	nextID  uint64
+	entries map[uint64]*queueEntry
+	ready   []uint64      // FIFO of ready IDs, entries in another state are skipped lazily
+	notify  chan struct{} // closed and replaced whenever a message may have become ready
+	closed  bool
+}
+
+// OpenDurableQueue opens the queue stored in dir, creating it if needed, and recovers
+// its state from the write-ahead log.
+func OpenDurableQueue(dir string, options ...QueueOption) (*DurableQueue, error) {
+	q := &DurableQueue{
+		dir:              dir,
+		visibility:       30 * time.Second,
+		maxDeliveries:    5,
+		compactThreshold: 1024,
+		syncWrites:       true,
+		clock:            SystemClock,
+		entries:          make(map[uint64]*queueEntry),
+		notify:           make(chan struct{}),
+	}
+	for _, option := range options {
+		option(q)
+	}
+	if q.maxDeliveries < 1 {
+		q.maxDeliveries = 1
+	}
+
+	if !fileutil.IsExist(dir) {
+		if err := fileutil.CreateDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	if err := q.replay(); err != nil {
+		return nil, err
+	}
+
+	wal, err := os.OpenFile(filepath.Join(dir, walFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	q.wal = wal
+	return q, nil
+}
+
+// replay rebuilds the queue state from the write-ahead log. A torn last record, left by
+// a crash in the middle of a write, is truncated.
+func (q *DurableQueue) replay() error {
+	path := filepath.Join(q.dir, walFile)
+	if !fileutil.IsExist(path) {
+		return nil
+	}
+
+	f, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var (
+		reader = bufio.NewReader(f)
+		offset int64 // end of the last valid record
+		lineNo int
+	)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if len(line) == 0 {
+			break
+		}
+		lineNo++
+
+		// A record without its newline can only be a torn write at the tail
+		var rec walRecord
+		if errors.Is(err, io.EOF) {
+			return os.Truncate(path, offset)
+		}
+		if err := json.Unmarshal(line, &rec); err != nil {
+			if _, peekErr := reader.Peek(1); peekErr == nil {
+				return fmt.Errorf("%w: %s line %d: %w", ErrQueueCorrupt, path, lineNo, err)
+			}
+			return os.Truncate(path, offset)
+		}
+
+		if err := q.apply(rec); err != nil {
+			return fmt.Errorf("%w: %s line %d: %w", ErrQueueCorrupt, path, lineNo, err)
+		}
+		offset += int64(len(line))
+		q.records++
+	}
+	return nil
+}
+
+// apply applies a log record to the in-memory state. Deliveries leave the message in the
+// ready list, so messages in flight when the process stopped are delivered again.
+func (q *DurableQueue) apply(rec walRecord) error {
+	q.nextID = max(q.nextID, rec.ID)
+	if rec.Op == opSequence {
+		return nil
+	}
+
+	e, ok := q.entries[rec.ID]
+	if !ok && rec.Op != opEnqueue {
+		return fmt.Errorf("%s of unknown message %d", rec.Op, rec.ID)
+	}
+
+	switch rec.Op {
+	case opEnqueue:
+		q.entries[rec.ID] = &queueEntry{msg: QueueMessage{
+			ID:         rec.ID,
+			Payload:    rec.Payload,
+			EnqueuedAt: rec.At,
+			Deliveries: rec.Deliveries,
+			LastError:  rec.Err,
+		}}
+		q.ready = append(q.ready, rec.ID)
+	case opDeliver:
+		e.msg.Deliveries++
+	case opAck:
+		delete(q.entries, rec.ID)
+		q.ready = removeID(q.ready, rec.ID)
+	case opNack:
+		e.msg.LastError = rec.Err
+		// Redelivered in FIFO order after the still ready messages
+		q.ready = append(removeID(q.ready, rec.ID), rec.ID)
+	case opDead:
+		e.state, e.msg.LastError = messageDead, rec.Err
+		q.ready = removeID(q.ready, rec.ID)
+	case opRedrive:
+		e.state, e.msg.Deliveries = messageReady, 0
+		q.ready = append(q.ready, rec.ID)
+	default:
+		return fmt.Errorf("unknown operation %q", rec.Op)
+	}
+	return nil
+}
+
+// removeID removes id from ids, preserving the order.
+func removeID(ids []uint64, id uint64) []uint64 {
+	for i, v := range ids {
+		if v == id {
+			return append(ids[:i], ids[i+1:]...)
+		}
+	}
+	return ids
+}
+
+// append writes records to the log. Locked.
+func (q *DurableQueue) append(recs ...walRecord) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rec := range recs {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+
+	if _, err := q.wal.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if q.syncWrites {
+		if err := q.wal.Sync(); err != nil {
+			return err
+		}
+	}
+
+	q.records += len(recs)
+	return nil
+}
+
+// maybeCompact compacts the log once it has grown past the threshold and most of its
+// records are obsolete. A failed compaction is retried by a later operation. Locked.
+func (q *DurableQueue) maybeCompact() {
+	if q.records < q.compactThreshold || q.records <= 2*len(q.entries) {
+		return
+	}
+	if err := q.compact(); err != nil {
+		log.Warnw("Failed to compact queue log", "dir", q.dir, "error", err)
+	}
+}
+
+// signal wakes up the goroutines waiting in Dequeue. Locked.
+func (q *DurableQueue) signal() {
+	close(q.notify)
+	q.notify = make(chan struct{})
+}
+
+// Enqueue appends a message to the queue and returns its ID once it is persisted.
+func (q *DurableQueue) Enqueue(payload []byte) (uint64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return 0, ErrQueueClosed
+	}
+
+	id := q.nextID + 1
+	now := q.clock.Now()
+	if err := q.append(walRecord{Op: opEnqueue, ID: id, Payload: payload, At: now}); err != nil {
+		return 0, err
+	}
+
+	q.nextID = id
+	q.entries[id] = &queueEntry{msg: QueueMessage{ID: id, Payload: payload, EnqueuedAt: now}}
+	q.ready = append(q.ready, id)
+	q.signal()
+	q.maybeCompact()
+	return id, nil
+}
+
+// Dequeue blocks until a message is available or ctx is done. The message stays
+// invisible to other consumers until it is acknowledged or its visibility timeout expires.
+func (q *DurableQueue) Dequeue(ctx context.Context) (*QueueMessage, error) {
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return nil, ErrQueueClosed
+		}
+
+		msg, wakeAt, err := q.next()
+		if msg != nil || err != nil {
+			q.mu.Unlock()
+			return msg, err
+		}
+		notify := q.notify
+		q.mu.Unlock()
+
+		// Wait for a new message or the earliest visibility deadline
+		var (
+			timer   Timer
+			timeout <-chan time.Time
+		)
+		if !wakeAt.IsZero() {
+			timer = q.clock.NewTimer(wakeAt.Sub(q.clock.Now()))
+			timeout = timer.C()
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-notify:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// next delivers the first ready message, after requeuing expired in-flight messages.
+// If nothing is ready it returns the earliest visibility deadline, if any. Locked.
+func (q *DurableQueue) next() (*QueueMessage, time.Time, error) {
+	now := q.clock.Now()
+	var wakeAt time.Time
+	for _, e := range q.entries {
+		if e.state != messageInFlight {
+			continue
+		}
+		if !e.deadline.After(now) {
+			e.state = messageReady
+			e.msg.LastError = "visibility timeout expired"
+			q.ready = append(q.ready, e.msg.ID)
+		} else if wakeAt.IsZero() || e.deadline.Before(wakeAt) {
+			wakeAt = e.deadline
+		}
+	}
+
+	for len(q.ready) > 0 {
+		id := q.ready[0]
+		e, ok := q.entries[id]
+		if !ok || e.state != messageReady {
+			q.ready = q.ready[1:]
+			continue
+		}
+
+		if e.msg.Deliveries >= q.maxDeliveries {
+			if err := q.append(walRecord{Op: opDead, ID: id, Err: e.msg.LastError}); err != nil {
+				return nil, time.Time{}, err
+			}
+			q.ready = q.ready[1:]
+			e.state = messageDead
+			continue
+		}
+
+		if err := q.append(walRecord{Op: opDeliver, ID: id}); err != nil {
+			return nil, time.Time{}, err
+		}
+		q.ready = q.ready[1:]
+		e.state, e.deadline = messageInFlight, now.Add(q.visibility)
+		e.msg.Deliveries++
+
+		q.maybeCompact()
+		msg := e.msg
+		return &msg, time.Time{}, nil
+	}
+	return nil, wakeAt, nil
+}
+
+// Ack acknowledges that the message was processed, removing it from the queue. Only a
+// message in flight, delivered by Dequeue and neither acknowledged nor rejected since,
+// can be acknowledged.
+func (q *DurableQueue) Ack(id uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	e, ok := q.entries[id]
+	if !ok || e.state != messageInFlight {
+		return fmt.Errorf("%w: %d", ErrUnknownMessage, id)
+	}
+
+	if err := q.append(walRecord{Op: opAck, ID: id}); err != nil {
+		return err
+	}
+	delete(q.entries, id)
+	q.maybeCompact()
+	return nil
+}
+
+// Nack reports that processing the message failed. The message is delivered again, or
+// moved to the dead-letter queue once it has been delivered the maximum number of times.
+func (q *DurableQueue) Nack(id uint64, cause error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	e, ok := q.entries[id]
+	if !ok || e.state != messageInFlight {
+		return fmt.Errorf("%w: %d", ErrUnknownMessage, id)
+	}
+
+	var reason string
+	if cause != nil {
+		reason = cause.Error()
+	}
+	e.msg.LastError = reason
+
+	if e.msg.Deliveries >= q.maxDeliveries {
+		if err := q.append(walRecord{Op: opDead, ID: id, Err: reason}); err != nil {
+			return err
+		}
+		e.state = messageDead
+		return nil
+	}
+
+	if err := q.append(walRecord{Op: opNack, ID: id, Err: reason}); err != nil {
+		return err
+	}
+	e.state = messageReady
+	q.ready = append(q.ready, id)
+	q.signal()
+	q.maybeCompact()
+	return nil
+}
+
+// DeadLetters returns the messages in the dead-letter queue, oldest first.
+func (q *DurableQueue) DeadLetters() []QueueMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var dead []QueueMessage
+	for _, e := range q.entries {
+		if e.state == messageDead {
+			dead = append(dead, e.msg)
+		}
+	}
+	sortMessages(dead)
+	return dead
+}
+
+// Redrive moves every dead-letter message back to the queue with a fresh delivery count
+// and returns how many were moved.
+func (q *DurableQueue) Redrive() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return 0, ErrQueueClosed
+	}
+
+	var dead []QueueMessage
+	for _, e := range q.entries {
+		if e.state == messageDead {
+			dead = append(dead, e.msg)
+		}
+	}
+	sortMessages(dead)
+
+	for i, msg := range dead {
+		if err := q.append(walRecord{Op: opRedrive, ID: msg.ID}); err != nil {
+			return i, err
+		}
+		e := q.entries[msg.ID]
+		e.state, e.msg.Deliveries = messageReady, 0
+		q.ready = append(q.ready, msg.ID)
+	}
+	if len(dead) > 0 {
+		q.signal()
+	}
+	return len(dead), nil
+}
+
+// sortMessages sorts messages by ID, which is their enqueue order.
+func sortMessages(msgs []QueueMessage) {
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].ID < msgs[j].ID })
+}
+
+// Stats returns the number of messages in each state.
+func (q *DurableQueue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var stats QueueStats
+	for _, e := range q.entries {
+		switch e.state {
+		case messageReady:
+			stats.Ready++
+		case messageInFlight:
+			stats.InFlight++
+		case messageDead:
+			stats.Dead++
+		}
+	}
+	return stats
+}
+
+// Compact rewrites the write-ahead log with only the live messages.
+func (q *DurableQueue) Compact() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	return q.compact()
+}
+
+// compact writes a snapshot of the live messages to a temporary file and atomically
+// replaces the log with it. Locked.
+func (q *DurableQueue) compact() error {
+	live := make([]QueueMessage, 0, len(q.entries))
+	for _, e := range q.entries {
+		live = append(live, e.msg)
+	}
+	sortMessages(live)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	recs := []walRecord{{Op: opSequence, ID: q.nextID}}
+	for _, msg := range live {
+		recs = append(recs, walRecord{
+			Op: opEnqueue, ID: msg.ID, Payload: msg.Payload, At: msg.EnqueuedAt,
+			Deliveries: msg.Deliveries, Err: msg.LastError,
+		})
+		if q.entries[msg.ID].state == messageDead {
+			recs = append(recs, walRecord{Op: opDead, ID: msg.ID, Err: msg.LastError})
+		}
+	}
+	for _, rec := range recs {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+
+	// The snapshot is opened for appending up front: once renamed it is the log, and
+	// there is no need to reopen it, which could fail after the old log is gone.
+	tmpPath := filepath.Join(q.dir, walTmpFile)
+	wal, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_TRUNC, 0o600) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	if _, err := wal.Write(buf.Bytes()); err != nil {
+		wal.Close()
+		return err
+	}
+	if err := wal.Sync(); err != nil {
+		wal.Close()
+		return err
+	}
+	if err := os.Rename(tmpPath, filepath.Join(q.dir, walFile)); err != nil {
+		wal.Close()
+		return err
+	}
+
+	q.wal.Close()
+	q.wal, q.records = wal, len(recs)
+
+	// Make the rename itself durable, or a crash could bring the old log back
+	return fileutil.SyncDir(q.dir)
+}
+
+// Close closes the queue. Messages in flight are delivered again when it is reopened.
+func (q *DurableQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	q.signal()
+	return q.wal.Close()
+}
+
+// Consume delivers messages to handler on the pool until ctx is done, then waits for the
+// handlers in progress. A message is acknowledged when handler returns nil and rejected
+// with Nack otherwise; a panic in handler counts as a failure.
+func (q *DurableQueue) Consume(
+	ctx context.Context, pool *Pool, handler func(ctx context.Context, msg *QueueMessage) error,
+) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		msg, err := q.Dequeue(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		future := Submit(ctx, pool, func(ctx context.Context) (struct{}, error) {
+			return struct{}{}, handler(ctx, msg)
+		})
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// If the acknowledgement fails the message is delivered again after its
+			// visibility timeout
+			if _, err := future.Await(context.Background()); err != nil {
+				_ = q.Nack(msg.ID, err)
+			} else {
+				_ = q.Ack(msg.ID)
+			}
+		}()
+	}
+}
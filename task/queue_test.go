@@ -0,0 +1,279 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func openTestQueue(t *testing.T, dir string, options ...QueueOption) *DurableQueue {
+	t.Helper()
+
+	q, err := OpenDurableQueue(dir, options...)
+	if err != nil {
+		t.Fatalf("OpenDurableQueue failed: %v", err)
+	}
+	return q
+}
+
+func mustDequeue(t *testing.T, q *DurableQueue) *QueueMessage {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, err := q.Dequeue(ctx)
+	if err != nil {
+		t.Fatalf("Dequeue failed: %v", err)
+	}
+	return msg
+}
+
+func TestDurableQueue_Recovery(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestQueue(t, dir)
+	for _, payload := range []string{"a", "b", "c"} {
+		if _, err := q.Enqueue([]byte(payload)); err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+	}
+
+	if msg := mustDequeue(t, q); string(msg.Payload) != "a" || q.Ack(msg.ID) != nil {
+		t.Fatalf("Expected to dequeue and ack a, got %q", msg.Payload)
+	}
+	if msg := mustDequeue(t, q); string(msg.Payload) != "b" {
+		t.Fatalf("Expected b, got %q", msg.Payload)
+	}
+	// Simulate a crash with b in flight
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	q = openTestQueue(t, dir)
+	defer q.Close()
+	if stats := q.Stats(); stats.Ready != 2 || stats.InFlight != 0 {
+		t.Errorf("Expected 2 ready messages after recovery, got %+v", stats)
+	}
+	msg := mustDequeue(t, q)
+	if string(msg.Payload) != "b" || msg.Deliveries != 2 {
+		t.Errorf("Expected b to be redelivered a second time, got %q after %d deliveries", msg.Payload, msg.Deliveries)
+	}
+	if id, _ := q.Enqueue([]byte("d")); id != 4 {
+		t.Errorf("Expected IDs to continue after recovery, got %d", id)
+	}
+}
+
+func TestDurableQueue_VisibilityTimeout(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	q := openTestQueue(t, t.TempDir(), WithQueueClock(clock), WithVisibilityTimeout(time.Minute))
+	defer q.Close()
+
+	id, _ := q.Enqueue([]byte("x"))
+	mustDequeue(t, q)
+
+	redelivered := make(chan *QueueMessage)
+	go func() {
+		msg, _ := q.Dequeue(context.Background())
+		redelivered <- msg
+	}()
+	waitUntil(t, func() bool { return clock.PendingTimers() == 1 })
+	clock.Advance(time.Minute)
+
+	msg := <-redelivered
+	if msg == nil || msg.ID != id || msg.Deliveries != 2 {
+		t.Fatalf("Expected message %d to be redelivered after the timeout, got %+v", id, msg)
+	}
+	if err := q.Ack(id); err != nil {
+		t.Errorf("Ack failed: %v", err)
+	}
+	if err := q.Ack(id); !errors.Is(err, ErrUnknownMessage) {
+		t.Errorf("Expected ErrUnknownMessage for a second ack, got %v", err)
+	}
+}
+
+func TestDurableQueue_AckNotInFlight(t *testing.T) {
+	q := openTestQueue(t, t.TempDir())
+	defer q.Close()
+
+	// A message no consumer has received cannot be acknowledged
+	id, _ := q.Enqueue([]byte("x"))
+	if err := q.Ack(id); !errors.Is(err, ErrUnknownMessage) {
+		t.Errorf("Expected ErrUnknownMessage for a ready message, got %v", err)
+	}
+	if stats := q.Stats(); stats.Ready != 1 {
+		t.Errorf("Expected the message to stay ready, got %+v", stats)
+	}
+
+	if msg := mustDequeue(t, q); msg.ID != id {
+		t.Fatalf("Expected message %d, got %d", id, msg.ID)
+	}
+	if err := q.Ack(id); err != nil {
+		t.Errorf("Ack failed: %v", err)
+	}
+}
+
+func TestDurableQueue_DeadLetter(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestQueue(t, dir, WithMaxDeliveries(2))
+
+	id, _ := q.Enqueue([]byte("poison"))
+	for range 2 {
+		msg := mustDequeue(t, q)
+		if err := q.Nack(msg.ID, errors.New("boom")); err != nil {
+			t.Fatalf("Nack failed: %v", err)
+		}
+	}
+
+	dead := q.DeadLetters()
+	if len(dead) != 1 || dead[0].ID != id || dead[0].LastError != "boom" {
+		t.Fatalf("Expected the message in the dead-letter queue, got %+v", dead)
+	}
+	q.Close()
+
+	// The dead-letter queue survives a restart and can be redriven
+	q = openTestQueue(t, dir, WithMaxDeliveries(2))
+	defer q.Close()
+	if stats := q.Stats(); stats.Dead != 1 || stats.Ready != 0 {
+		t.Fatalf("Expected 1 dead message after recovery, got %+v", stats)
+	}
+	if n, err := q.Redrive(); n != 1 || err != nil {
+		t.Fatalf("Expected 1 message redriven, got %d, %v", n, err)
+	}
+	if msg := mustDequeue(t, q); msg.ID != id || msg.Deliveries != 1 {
+		t.Errorf("Expected a fresh delivery of %d, got %+v", id, msg)
+	}
+}
+
+func TestDurableQueue_TornWrite(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestQueue(t, dir)
+	_, _ = q.Enqueue([]byte("ok"))
+	q.Close()
+
+	path := filepath.Join(dir, walFile)
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	_, _ = f.WriteString(`{"op":"enq","id":2,"pay`)
+	f.Close()
+
+	q = openTestQueue(t, dir)
+	if stats := q.Stats(); stats.Ready != 1 {
+		t.Errorf("Expected the torn record to be dropped, got %+v", stats)
+	}
+	if _, err := q.Enqueue([]byte("next")); err != nil {
+		t.Errorf("Enqueue after recovery failed: %v", err)
+	}
+	q.Close()
+
+	// Corruption in the middle of the log is not silently dropped
+	data, _ := os.ReadFile(path)
+	_ = os.WriteFile(path, append([]byte("garbage\n"), data...), 0o600)
+	if _, err := OpenDurableQueue(dir); !errors.Is(err, ErrQueueCorrupt) {
+		t.Errorf("Expected ErrQueueCorrupt, got %v", err)
+	}
+}
+
+func TestDurableQueue_Compact(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestQueue(t, dir, WithCompactThreshold(16), WithMaxDeliveries(1))
+
+	for range 20 {
+		_, _ = q.Enqueue([]byte("done"))
+		_ = q.Ack(mustDequeue(t, q).ID)
+	}
+	_, _ = q.Enqueue([]byte("dead"))
+	_ = q.Nack(mustDequeue(t, q).ID, errors.New("boom"))
+	keep, _ := q.Enqueue([]byte("keep"))
+	if err := q.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	q.Close()
+
+	if info, _ := os.Stat(filepath.Join(dir, walFile)); info.Size() > 512 {
+		t.Errorf("Expected the compacted log to be small, got %d bytes", info.Size())
+	}
+
+	q = openTestQueue(t, dir)
+	defer q.Close()
+	if stats := q.Stats(); stats.Ready != 1 || stats.Dead != 1 {
+		t.Errorf("Expected 1 ready and 1 dead message after compaction, got %+v", stats)
+	}
+	if msg := mustDequeue(t, q); msg.ID != keep {
+		t.Errorf("Expected message %d, got %d", keep, msg.ID)
+	}
+	if id, _ := q.Enqueue(nil); id != keep+1 {
+		t.Errorf("Expected IDs to continue after compaction, got %d", id)
+	}
+}
+
+func TestDurableQueue_AppendAfterCompact(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestQueue(t, dir)
+
+	_, _ = q.Enqueue([]byte("before"))
+	if err := q.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	_, _ = q.Enqueue([]byte("after"))
+	q.Close()
+
+	// The message appended after compaction must land in the new log
+	q = openTestQueue(t, dir)
+	defer q.Close()
+	if stats := q.Stats(); stats.Ready != 2 {
+		t.Errorf("Expected 2 ready messages after reopening, got %+v", stats)
+	}
+	if _, err := os.Stat(filepath.Join(dir, walTmpFile)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected no leftover snapshot file, got %v", err)
+	}
+}
+
+func TestDurableQueue_Consume(t *testing.T) {
+	q := openTestQueue(t, t.TempDir())
+	defer q.Close()
+	pool := NewPool(WithPoolWorkers(4))
+	defer pool.Stop(context.Background())
+
+	for range 10 {
+		_, _ = q.Enqueue([]byte("job"))
+	}
+
+	var (
+		mu       sync.Mutex
+		attempts = make(map[uint64]int)
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- q.Consume(ctx, pool, func(_ context.Context, msg *QueueMessage) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			attempts[msg.ID]++
+			if msg.ID%3 == 0 && attempts[msg.ID] == 1 {
+				return errors.New("transient")
+			}
+			return nil
+		})
+	}()
+
+	waitUntil(t, func() bool { return q.Stats() == QueueStats{} })
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Consume failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for id := uint64(1); id <= 10; id++ {
+		want := 1
+		if id%3 == 0 {
+			want = 2
+		}
+		if attempts[id] != want {
+			t.Errorf("Message %d: expected %d attempts, got %d", id, want, attempts[id])
+		}
+	}
+}
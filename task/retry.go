@@ -0,0 +1,186 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff computes the delay to wait before a retry attempt
+type Backoff interface {
+	// Next returns the delay before the retry following the given failed attempt
+	// (starting at 1). prev is the delay returned for the previous attempt.
+	Next(attempt int, prev time.Duration) time.Duration
+}
+
+// ConstantBackoff waits the same delay before every retry
+type ConstantBackoff time.Duration
+
+// Next implements Backoff
+func (b ConstantBackoff) Next(int, time.Duration) time.Duration { return time.Duration(b) }
+
+// ExponentialBackoff multiplies the delay by Multiplier after every attempt, capped at Max.
+// Jitter in [0, 1] randomizes each delay by up to that fraction of itself.
+type ExponentialBackoff struct {
+	Base       time.Duration // delay before the first retry
+	Max        time.Duration // upper bound of the delay, zero means unbounded
+	Multiplier float64       // growth factor, defaults to 2
+	Jitter     float64       // random fraction subtracted from each delay
+}
+
+// Next implements Backoff
+func (b ExponentialBackoff) Next(attempt int, _ time.Duration) time.Duration {
+	multiplier := b.Multiplier
+	if multiplier <= 1 {
+		multiplier = 2
+	}
+
+	// Without Max the delay still has to fit in a time.Duration
+	limit := time.Duration(math.MaxInt64)
+	if b.Max > 0 {
+		limit = b.Max
+	}
+
+	delay := float64(b.Base)
+	for i := 1; i < attempt && delay < float64(limit); i++ {
+		delay *= multiplier
+	}
+	delay = min(delay, float64(limit))
+
+	if b.Jitter > 0 {
+		jitter := min(b.Jitter, 1)
+		delay -= delay * jitter * rand.Float64() //nolint:gosec
+	}
+	if delay >= float64(limit) {
+		return limit // float64(limit) may round past the largest Duration
+	}
+	return time.Duration(delay)
+}
+
+// DecorrelatedJitterBackoff picks each delay at random between Base and three times the
+// previous delay, capped at Max. It spreads out retries of many concurrent clients.
+type DecorrelatedJitterBackoff struct {
+	Base time.Duration // minimum delay
+	Max  time.Duration // upper bound of the delay, zero means unbounded
+}
+
+// Next implements Backoff
+func (b DecorrelatedJitterBackoff) Next(_ int, prev time.Duration) time.Duration {
+	if prev < b.Base {
+		prev = b.Base
+	}
+
+	upper := time.Duration(math.MaxInt64)
+	if prev < upper/3 {
+		upper = 3 * prev
+	}
+	delay := b.Base
+	if upper > b.Base {
+		delay += rand.N(upper - b.Base) //nolint:gosec
+	}
+	if b.Max > 0 && delay > b.Max {
+		delay = b.Max
+	}
+	return delay
+}
+
+// RetryPolicy describes how a failing task is retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values below 1 mean a single attempt.
+	MaxAttempts int
+
+	// Backoff computes the delay between attempts. Nil retries immediately.
+	Backoff Backoff
+
+	// Retryable classifies errors. Nil retries every error except permanent ones.
+	Retryable func(error) bool
+
+	// OnRetry is called after a failed attempt that will be retried, before waiting.
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+// AttemptError is a failed attempt that is going to be retried
+type AttemptError struct {
+	Attempt int           // number of the failed attempt, starting at 1
+	Delay   time.Duration // delay before the next attempt
+	Err     error         // error returned by the attempt
+}
+
+func (e *AttemptError) Error() string {
+	return fmt.Sprintf("attempt %d failed, retrying in %v: %v", e.Attempt, e.Delay, e.Err)
+}
+
+func (e *AttemptError) Unwrap() error { return e.Err }
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that Retry stops immediately and returns err.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Retry calls fn until it succeeds, the policy gives up, or ctx is done.
+// It returns the result of the last attempt. If ctx is done while waiting between
+// attempts, the returned error wraps both ctx.Err() and the last attempt's error.
+func Retry[T any](ctx context.Context, fn func(ctx context.Context) (T, error), policy RetryPolicy) (T, error) {
+	var prev time.Duration
+
+	for attempt := 1; ; attempt++ {
+		result, err := fn(ctx)
+		if err == nil {
+			return result, nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return result, permanent.err
+		}
+		if attempt >= policy.MaxAttempts || ctx.Err() != nil ||
+			(policy.Retryable != nil && !policy.Retryable(err)) {
+			return result, err
+		}
+
+		var delay time.Duration
+		if policy.Backoff != nil {
+			delay = policy.Backoff.Next(attempt, prev)
+		}
+		prev = delay
+
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, err, delay)
+		}
+
+		if ctxErr := sleepContext(ctx, delay); ctxErr != nil {
+			return result, errors.Join(ctxErr, err)
+		}
+	}
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,158 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errFlaky = errors.New("flaky downstream")
+
+func TestRetry(t *testing.T) {
+	var calls int
+	var delays []time.Duration
+
+	got, err := Retry(context.Background(), func(_ context.Context) (string, error) {
+		calls++
+		if calls < 3 {
+			return "", errFlaky
+		}
+		return "ok", nil
+	}, RetryPolicy{
+		MaxAttempts: 5,
+		Backoff:     ExponentialBackoff{Base: time.Millisecond, Max: 10 * time.Millisecond},
+		OnRetry: func(_ int, _ error, delay time.Duration) {
+			delays = append(delays, delay)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Retry failed: %v", err)
+	}
+	if got != "ok" || calls != 3 {
+		t.Errorf("Expected ok after 3 calls, got %q after %d calls", got, calls)
+	}
+	if len(delays) != 2 || delays[0] != time.Millisecond || delays[1] != 2*time.Millisecond {
+		t.Errorf("Unexpected backoff delays: %v", delays)
+	}
+}
+
+func TestRetry_GivesUp(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    RetryPolicy
+		err       error
+		wantCalls int
+	}{
+		{"max attempts", RetryPolicy{MaxAttempts: 3}, errFlaky, 3},
+		{"not retryable", RetryPolicy{
+			MaxAttempts: 3,
+			Retryable:   func(err error) bool { return !errors.Is(err, errFlaky) },
+		}, errFlaky, 1},
+		{"permanent", RetryPolicy{MaxAttempts: 3}, Permanent(errFlaky), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			_, err := Retry(context.Background(), func(_ context.Context) (int, error) {
+				calls++
+				return 0, tt.err
+			}, tt.policy)
+			if !errors.Is(err, errFlaky) {
+				t.Errorf("Expected errFlaky, got %v", err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("Expected %d calls, got %d", tt.wantCalls, calls)
+			}
+		})
+	}
+}
+
+func TestRetry_ContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := Retry(ctx, func(_ context.Context) (int, error) {
+		return 0, errFlaky
+	}, RetryPolicy{MaxAttempts: 10, Backoff: ConstantBackoff(time.Hour)})
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, errFlaky) {
+		t.Errorf("Expected deadline and last error, got %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	exp := ExponentialBackoff{Base: 10 * time.Millisecond, Max: 50 * time.Millisecond, Jitter: 0.5}
+	for attempt := 1; attempt < 10; attempt++ {
+		if d := exp.Next(attempt, 0); d < 0 || d > 50*time.Millisecond {
+			t.Errorf("Exponential delay out of range at attempt %d: %v", attempt, d)
+		}
+	}
+
+	// Without Max the delay grows until the largest Duration, never wrapping around
+	unbounded := ExponentialBackoff{Base: time.Second}
+	prevDelay := time.Duration(0)
+	for attempt := 1; attempt < 200; attempt++ {
+		d := unbounded.Next(attempt, 0)
+		if d < prevDelay {
+			t.Fatalf("Unbounded exponential delay overflowed at attempt %d: %v", attempt, d)
+		}
+		prevDelay = d
+	}
+	if prevDelay != time.Duration(math.MaxInt64) {
+		t.Errorf("Expected the unbounded delay to saturate, got %v", prevDelay)
+	}
+	if d := (DecorrelatedJitterBackoff{Base: time.Second}).Next(1, prevDelay); d < time.Second {
+		t.Errorf("Decorrelated jitter delay overflowed: %v", d)
+	}
+
+	dj := DecorrelatedJitterBackoff{Base: 10 * time.Millisecond, Max: 100 * time.Millisecond}
+	var prev time.Duration
+	for attempt := 1; attempt < 20; attempt++ {
+		d := dj.Next(attempt, prev)
+		if d < dj.Base || d > dj.Max {
+			t.Errorf("Decorrelated jitter delay out of range at attempt %d: %v", attempt, d)
+		}
+		prev = d
+	}
+}
+
+func TestTaskProcessor_WithRetry(t *testing.T) {
+	var attemptErrors atomic.Int32
+	var calls [2]atomic.Int32
+
+	processor := NewTaskProcessor(
+		WithMaxWorkerCount(2),
+		WithRetry(RetryPolicy{MaxAttempts: 3}),
+		WithErrorHandler(func(err error) {
+			var attemptErr *AttemptError
+			if errors.As(err, &attemptErr) {
+				attemptErrors.Add(1)
+			}
+		}),
+	)
+
+	// The first chunk succeeds on its second attempt, the second one always fails
+	err := processor.ProcessInChunks(context.Background(), []any{0, 1},
+		func(_ context.Context, chunk []any) (any, error) {
+			i, _ := chunk[0].(int)
+			if n := calls[i].Add(1); i == 0 && n > 1 {
+				return nil, nil
+			}
+			return nil, errFlaky
+		})
+	if err != nil {
+		t.Fatalf("ProcessInChunks failed: %v", err)
+	}
+
+	stats := processor.Metrics()
+	if stats.ErrorCount != 1 {
+		t.Errorf("Expected 1 final failure, got %d", stats.ErrorCount)
+	}
+	if stats.RetryCount != 3 || attemptErrors.Load() != 3 {
+		t.Errorf("Expected retries to be reported, got %d retries and %d attempt errors",
+			stats.RetryCount, attemptErrors.Load())
+	}
+}
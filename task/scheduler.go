@@ -0,0 +1,470 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kydance/ziwi/log"
+)
+
+var (
+	// ErrDuplicateJob is returned when adding a job whose name is already scheduled
+	ErrDuplicateJob = errors.New("task: duplicate job")
+	// ErrSchedulerStopped is returned when adding a job to a stopped Scheduler
+	ErrSchedulerStopped = errors.New("task: scheduler stopped")
+)
+
+// MisfirePolicy decides what happens to fire times missed while a job could not run,
+// e.g. because the process was suspended or the clock jumped forward
+type MisfirePolicy int
+
+const (
+	// MisfireRunOnce runs the job once for all missed fire times
+	MisfireRunOnce MisfirePolicy = iota
+	// MisfireSkip skips the missed fire times and waits for the next one
+	MisfireSkip
+	// MisfireRunAll runs the job once for every missed fire time, back to back
+	MisfireRunAll
+)
+
+// RunStatus is the outcome of a scheduled run
+type RunStatus int
+
+const (
+	RunSucceeded RunStatus = iota // the job returned nil
+	RunFailed                     // the job returned an error or panicked
+	RunSkipped                    // the job did not run, see RunRecord.Reason
+)
+
+func (s RunStatus) String() string {
+	switch s {
+	case RunSucceeded:
+		return "succeeded"
+	case RunFailed:
+		return "failed"
+	case RunSkipped:
+		return "skipped"
+	default:
+		return fmt.Sprintf("RunStatus(%d)", int(s))
+	}
+}
+
+// RunRecord describes a single fire time of a job
+type RunRecord struct {
+	Job       string
+	Scheduled time.Time     // fire time computed from the schedule, before jitter
+	Start     time.Time     // when the run started, zero if skipped
+	Duration  time.Duration // how long the run took
+	Status    RunStatus
+	Reason    string // why the run was skipped: "overlap" or "misfire"
+	Err       error  // error returned by the job, if it failed
+}
+
+// JobOption defines the functional option type for job configuration
+type JobOption func(*schedJob)
+
+// WithJitter delays every run of the job by a random duration in [0, d), which spreads
+// jobs sharing a schedule.
+func WithJitter(d time.Duration) JobOption {
+	return func(j *schedJob) { j.jitter = d }
+}
+
+// WithMisfirePolicy configures how the job handles fire times it missed by more than
+// threshold. A non-positive threshold keeps the default of one second. When the timer
+// fires within threshold but later fire times have passed too, as a large jitter can
+// cause, only the last one runs and the earlier ones are recorded as skipped, unless
+// the policy is MisfireRunAll.
+func WithMisfirePolicy(policy MisfirePolicy, threshold time.Duration) JobOption {
+	return func(j *schedJob) {
+		j.misfire = policy
+		if threshold > 0 {
+			j.misfireThreshold = threshold
+		}
+	}
+}
+
+// SchedulerOption defines the functional option type for Scheduler configuration
+type SchedulerOption func(*Scheduler)
+
+// WithClock configures the clock of the Scheduler, tests use a ManualClock
+func WithClock(clock Clock) SchedulerOption {
+	return func(s *Scheduler) { s.clock = clock }
+}
+
+// WithSchedulerPool configures the pool the jobs run on. By default the Scheduler
+// creates its own pool and stops it on Stop.
+func WithSchedulerPool(pool *Pool) SchedulerOption {
+	return func(s *Scheduler) { s.pool = pool }
+}
+
+// WithHistoryLimit configures how many run records are kept per job, 100 by default
+func WithHistoryLimit(n int) SchedulerOption {
+	return func(s *Scheduler) { s.historyLimit = n }
+}
+
+// WithSchedulerLogger configures the logger of the Scheduler, the global ziwi logger
+// by default
+func WithSchedulerLogger(logger log.Logger) SchedulerOption {
+	return func(s *Scheduler) { s.logger = logger }
+}
+
+// schedJob is a job registered with a Scheduler
+type schedJob struct {
+	name             string
+	schedule         Schedule
+	fn               func(ctx context.Context) error
+	jitter           time.Duration
+	misfire          MisfirePolicy
+	misfireThreshold time.Duration
+
+	running atomic.Bool // a run is in progress, used to prevent overlap
+	cancel  context.CancelFunc
+
+	mu      sync.Mutex
+	next    time.Time // next fire time
+	history []RunRecord
+}
+
+// Scheduler runs jobs periodically according to cron expressions or fixed intervals.
+// Runs of the same job never overlap: a fire time reached while the previous run is
+// still in progress is recorded as skipped.
+type Scheduler struct {
+	clock        Clock
+	pool         *Pool
+	ownPool      bool
+	historyLimit int
+	logger       log.Logger
+
+	mu      sync.Mutex
+	jobs    map[string]*schedJob
+	ctx     context.Context // context of the job loops, nil until Start
+	stopped bool
+
+	runCtx    context.Context // context passed to the jobs, cancelled once Stop gives up
+	runCancel context.CancelFunc
+	loopWg    sync.WaitGroup
+	runWg     sync.WaitGroup
+	loopStop  context.CancelFunc
+}
+
+// NewScheduler creates a new Scheduler. Jobs can be added before or after Start.
+func NewScheduler(options ...SchedulerOption) *Scheduler {
+	s := &Scheduler{
+		clock:        SystemClock,
+		historyLimit: 100,
+		jobs:         make(map[string]*schedJob),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if s.pool == nil {
+		s.pool, s.ownPool = NewPool(), true
+	}
+	if s.historyLimit <= 0 {
+		s.historyLimit = 1
+	}
+	s.runCtx, s.runCancel = context.WithCancel(context.Background())
+	return s
+}
+
+// AddCron schedules fn according to the cron expression expr, see ParseCron.
+func (s *Scheduler) AddCron(name, expr string, fn func(ctx context.Context) error, options ...JobOption) error {
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		return err
+	}
+	return s.Add(name, schedule, fn, options...)
+}
+
+// Add schedules fn under a unique name. If the Scheduler is running the job starts
+// immediately.
+func (s *Scheduler) Add(
+	name string, schedule Schedule, fn func(ctx context.Context) error, options ...JobOption,
+) error {
+	j := &schedJob{name: name, schedule: schedule, fn: fn, misfireThreshold: time.Second}
+	for _, option := range options {
+		option(j)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return ErrSchedulerStopped
+	}
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("%w: %q", ErrDuplicateJob, name)
+	}
+	s.jobs[name] = j
+	if s.ctx != nil {
+		s.startLoop(j)
+	}
+	return nil
+}
+
+// Remove unschedules the named job. A run in progress is not interrupted.
+func (s *Scheduler) Remove(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[name]
+	if !ok {
+		return false
+	}
+	delete(s.jobs, name)
+	if j.cancel != nil {
+		j.cancel()
+	}
+	return true
+}
+
+// Start starts scheduling the jobs. Scheduling stops when ctx is done or Stop is called.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx != nil || s.stopped {
+		return
+	}
+	s.ctx, s.loopStop = context.WithCancel(ctx)
+	for _, j := range s.jobs {
+		s.startLoop(j)
+	}
+}
+
+// Stop stops scheduling and waits for the runs in progress to finish. If ctx is done
+// first the runs are cancelled and ctx.Err() is returned.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopped = true
+	if s.loopStop != nil {
+		s.loopStop()
+	}
+	s.mu.Unlock()
+	s.loopWg.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		s.runWg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	s.runCancel()
+
+	if s.ownPool {
+		if stopErr := s.pool.Stop(ctx); err == nil {
+			err = stopErr
+		}
+	}
+	return err
+}
+
+// History returns the recorded runs of the named job, oldest first.
+func (s *Scheduler) History(name string) []RunRecord {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return append([]RunRecord(nil), j.history...)
+}
+
+// NextRun returns the next fire time of the named job, before jitter. It is zero until
+// the Scheduler has started.
+func (s *Scheduler) NextRun(name string) (time.Time, bool) {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return time.Time{}, false
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.next, true
+}
+
+// Jobs returns the names of the scheduled jobs, sorted.
+func (s *Scheduler) Jobs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.jobs))
+	for name := range s.jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// startLoop starts the scheduling goroutine of j. Locked.
+func (s *Scheduler) startLoop(j *schedJob) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	j.cancel = cancel
+
+	s.loopWg.Add(1)
+	go func() {
+		defer s.loopWg.Done()
+		s.loop(ctx, j)
+	}()
+}
+
+// loop waits for each fire time of j and dispatches the due runs.
+func (s *Scheduler) loop(ctx context.Context, j *schedJob) {
+	next := j.schedule.Next(s.clock.Now())
+	for !next.IsZero() {
+		j.setNext(next)
+
+		fireAt := next
+		if j.jitter > 0 {
+			fireAt = fireAt.Add(rand.N(j.jitter)) //nolint:gosec
+		}
+		timer := s.clock.NewTimer(fireAt.Sub(s.clock.Now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		// Collect every fire time that has passed, the first one may be late
+		now := s.clock.Now()
+		due := []time.Time{next}
+		for next = j.schedule.Next(next); !next.IsZero() && !next.After(now); next = j.schedule.Next(next) {
+			due = append(due, next)
+		}
+
+		// Even on time, the jitter may overlap the next fire times: every fire time but
+		// the last is then a miss too, handled by the misfire policy
+		late := now.Sub(fireAt) > j.misfireThreshold
+		if late || len(due) > 1 {
+			s.logw(levelWarn, "Job misfired", "job", j.name, "scheduled", due[0], "late", now.Sub(fireAt), "missed", len(due))
+		}
+		switch {
+		case late && j.misfire == MisfireSkip:
+			for _, t := range due {
+				j.record(RunRecord{Job: j.name, Scheduled: t, Status: RunSkipped, Reason: "misfire"}, s.historyLimit)
+			}
+		case j.misfire == MisfireRunAll:
+			s.dispatch(j, due)
+		default:
+			for _, t := range due[:len(due)-1] {
+				j.record(RunRecord{Job: j.name, Scheduled: t, Status: RunSkipped, Reason: "misfire"}, s.historyLimit)
+			}
+			s.dispatch(j, due[len(due)-1:])
+		}
+	}
+}
+
+// dispatch submits the runs of j for the given fire times to the pool, unless a previous
+// run is still in progress.
+func (s *Scheduler) dispatch(j *schedJob, due []time.Time) {
+	if !j.running.CompareAndSwap(false, true) {
+		s.logw(levelWarn, "Job still running, skipping run", "job", j.name, "scheduled", due[0])
+		for _, t := range due {
+			j.record(RunRecord{Job: j.name, Scheduled: t, Status: RunSkipped, Reason: "overlap"}, s.historyLimit)
+		}
+		return
+	}
+
+	s.runWg.Add(1)
+	future := Submit(s.runCtx, s.pool, func(ctx context.Context) (struct{}, error) {
+		for _, t := range due {
+			s.run(ctx, j, t)
+		}
+		return struct{}{}, nil
+	})
+
+	go func() {
+		defer s.runWg.Done()
+		defer j.running.Store(false)
+
+		// The pool only fails the future without running it if the task was rejected
+		if _, err := future.Await(context.Background()); err != nil {
+			s.logw(levelError, "Failed to submit job", "job", j.name, "error", err)
+			for _, t := range due {
+				j.record(RunRecord{Job: j.name, Scheduled: t, Status: RunFailed, Err: err}, s.historyLimit)
+			}
+		}
+	}()
+}
+
+// run executes a single run of j and records it.
+func (s *Scheduler) run(ctx context.Context, j *schedJob, scheduled time.Time) {
+	start := s.clock.Now()
+	_, err := safeCall(ctx, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, j.fn(ctx)
+	})
+
+	record := RunRecord{Job: j.name, Scheduled: scheduled, Start: start, Duration: s.clock.Now().Sub(start), Err: err}
+	if err != nil {
+		record.Status = RunFailed
+		s.logw(levelError, "Job failed", "job", j.name, "scheduled", scheduled, "error", err)
+	} else {
+		s.logw(levelInfo, "Job finished", "job", j.name, "scheduled", scheduled, "duration", record.Duration)
+	}
+	j.record(record, s.historyLimit)
+}
+
+// logLevel selects the logging method used by Scheduler.logw
+type logLevel int
+
+const (
+	levelInfo logLevel = iota
+	levelWarn
+	levelError
+)
+
+// logw logs through the configured logger, or the global ziwi logger if none is set.
+func (s *Scheduler) logw(level logLevel, msg string, keysAndValues ...any) {
+	switch {
+	case s.logger == nil && level == levelError:
+		log.Errorw(msg, keysAndValues...)
+	case s.logger == nil && level == levelWarn:
+		log.Warnw(msg, keysAndValues...)
+	case s.logger == nil:
+		log.Infow(msg, keysAndValues...)
+	case level == levelError:
+		s.logger.Errorw(msg, keysAndValues...)
+	case level == levelWarn:
+		s.logger.Warnw(msg, keysAndValues...)
+	default:
+		s.logger.Infow(msg, keysAndValues...)
+	}
+}
+
+func (j *schedJob) setNext(next time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.next = next
+}
+
+// record appends r to the history of j, keeping at most limit records.
+func (j *schedJob) record(r RunRecord, limit int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.history = append(j.history, r)
+	if len(j.history) > limit {
+		j.history = append(j.history[:0], j.history[len(j.history)-limit:]...)
+	}
+}
@@ -0,0 +1,212 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitUntil polls cond until it holds or a second has passed.
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// idle reports whether the named job has no run in progress.
+func idle(s *Scheduler, name string) bool {
+	s.mu.Lock()
+	j := s.jobs[name]
+	s.mu.Unlock()
+	return j != nil && !j.running.Load()
+}
+
+// newTestScheduler starts a Scheduler driven by a ManualClock with a single job.
+func newTestScheduler(
+	t *testing.T, fn func(ctx context.Context) error, options ...JobOption,
+) (*Scheduler, *ManualClock) {
+	t.Helper()
+
+	clock := NewManualClock(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	s := NewScheduler(WithClock(clock))
+	if err := s.Add("job", Every(time.Minute), fn, options...); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	s.Start(context.Background())
+	t.Cleanup(func() { _ = s.Stop(context.Background()) })
+
+	waitUntil(t, func() bool { return clock.PendingTimers() == 1 })
+	return s, clock
+}
+
+func TestScheduler_Interval(t *testing.T) {
+	s, clock := newTestScheduler(t, func(context.Context) error { return nil })
+
+	start := clock.Now()
+	for i := 1; i <= 3; i++ {
+		clock.Advance(time.Minute)
+		waitUntil(t, func() bool {
+			return len(s.History("job")) == i && clock.PendingTimers() == 1 && idle(s, "job")
+		})
+	}
+
+	for i, r := range s.History("job") {
+		if r.Status != RunSucceeded {
+			t.Errorf("Run %d: expected succeeded, got %v", i, r.Status)
+		}
+		if want := start.Add(time.Duration(i+1) * time.Minute); !r.Scheduled.Equal(want) {
+			t.Errorf("Run %d: expected scheduled at %v, got %v", i, want, r.Scheduled)
+		}
+	}
+	if next, _ := s.NextRun("job"); !next.Equal(start.Add(4 * time.Minute)) {
+		t.Errorf("Expected next run at %v, got %v", start.Add(4*time.Minute), next)
+	}
+}
+
+func TestScheduler_NoOverlap(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	s, clock := newTestScheduler(t, func(context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+
+	clock.Advance(time.Minute)
+	<-started
+	waitUntil(t, func() bool { return clock.PendingTimers() == 1 })
+
+	clock.Advance(time.Minute)
+	waitUntil(t, func() bool { return len(s.History("job")) == 1 })
+	close(release)
+	waitUntil(t, func() bool { return len(s.History("job")) == 2 })
+
+	history := s.History("job")
+	if history[0].Status != RunSkipped || history[0].Reason != "overlap" {
+		t.Errorf("Expected the second fire time to be skipped for overlap, got %+v", history[0])
+	}
+	if history[1].Status != RunSucceeded {
+		t.Errorf("Expected the first run to succeed, got %+v", history[1])
+	}
+}
+
+func TestScheduler_Misfire(t *testing.T) {
+	tests := []struct {
+		policy             MisfirePolicy
+		wantRuns, wantSkip int
+	}{
+		{MisfireRunOnce, 1, 2},
+		{MisfireSkip, 0, 3},
+		{MisfireRunAll, 3, 0},
+	}
+	for _, tt := range tests {
+		s, clock := newTestScheduler(t, func(context.Context) error { return nil },
+			WithMisfirePolicy(tt.policy, time.Second))
+
+		clock.Advance(3 * time.Minute)
+		waitUntil(t, func() bool { return len(s.History("job")) == tt.wantRuns+tt.wantSkip })
+
+		runs, skips := 0, 0
+		for _, r := range s.History("job") {
+			switch {
+			case r.Status == RunSucceeded:
+				runs++
+			case r.Status == RunSkipped && r.Reason == "misfire":
+				skips++
+			}
+		}
+		if runs != tt.wantRuns || skips != tt.wantSkip {
+			t.Errorf("Policy %d: expected %d runs and %d skips, got %d and %d",
+				tt.policy, tt.wantRuns, tt.wantSkip, runs, skips)
+		}
+	}
+}
+
+func TestScheduler_JitterOverlap(t *testing.T) {
+	// The jitter can delay a run past the next fire times without the run being late
+	s, clock := newTestScheduler(t, func(context.Context) error { return nil },
+		WithJitter(3*time.Minute))
+	start := clock.Now()
+	first, _ := s.NextRun("job")
+
+	for {
+		clock.Advance(time.Second)
+		waitUntil(t, func() bool { return clock.PendingTimers() == 1 })
+		if next, _ := s.NextRun("job"); !next.Equal(first) {
+			break
+		}
+	}
+
+	// Every fire time that has passed is either run or recorded as skipped
+	passed := int(clock.Now().Sub(start) / time.Minute)
+	waitUntil(t, func() bool { return len(s.History("job")) == passed })
+	runs := 0
+	for _, r := range s.History("job") {
+		if r.Status == RunSucceeded {
+			runs++
+		} else if r.Status != RunSkipped || r.Reason != "misfire" {
+			t.Errorf("Unexpected run record %+v", r)
+		}
+	}
+	if runs != 1 {
+		t.Errorf("Expected a single run for %d passed fire times, got %d", passed, runs)
+	}
+}
+
+func TestScheduler_Failure(t *testing.T) {
+	errBoom := errors.New("boom")
+	calls := 0
+	s, clock := newTestScheduler(t, func(context.Context) error {
+		calls++
+		if calls == 1 {
+			return errBoom
+		}
+		panic("boom")
+	})
+
+	clock.Advance(time.Minute)
+	waitUntil(t, func() bool { return len(s.History("job")) == 1 && clock.PendingTimers() == 1 })
+	clock.Advance(time.Minute)
+	waitUntil(t, func() bool { return len(s.History("job")) == 2 })
+
+	history := s.History("job")
+	if history[0].Status != RunFailed || !errors.Is(history[0].Err, errBoom) {
+		t.Errorf("Expected the first run to fail with errBoom, got %+v", history[0])
+	}
+	var panicErr *PanicError
+	if history[1].Status != RunFailed || !errors.As(history[1].Err, &panicErr) {
+		t.Errorf("Expected the second run to fail with a *PanicError, got %+v", history[1])
+	}
+}
+
+func TestScheduler_Jobs(t *testing.T) {
+	s := NewScheduler()
+	defer func() { _ = s.Stop(context.Background()) }()
+
+	noop := func(context.Context) error { return nil }
+	if err := s.AddCron("b", "@daily", noop); err != nil {
+		t.Fatalf("AddCron failed: %v", err)
+	}
+	if err := s.Add("a", Every(time.Hour), noop); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := s.Add("a", Every(time.Hour), noop); !errors.Is(err, ErrDuplicateJob) {
+		t.Errorf("Expected ErrDuplicateJob, got %v", err)
+	}
+	if err := s.AddCron("c", "not a cron", noop); err == nil {
+		t.Errorf("Expected an invalid expression to be rejected")
+	}
+
+	if !s.Remove("b") || s.Remove("b") {
+		t.Errorf("Expected b to be removed exactly once")
+	}
+	if jobs := s.Jobs(); len(jobs) != 1 || jobs[0] != "a" {
+		t.Errorf("Expected jobs [a], got %v", jobs)
+	}
+}
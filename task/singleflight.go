@@ -0,0 +1,93 @@
+package task
+
+import (
+	"context"
+	"sync"
+)
+
+// flight is an in-progress or completed Group call
+type flight[V any] struct {
+	done    chan struct{} // closed once the call has returned
+	value   V
+	err     error
+	waiters int                // callers still waiting for the result
+	cancel  context.CancelFunc // cancels the call once every caller has given up
+}
+
+// Group deduplicates concurrent calls with the same key: while a call for a key is in
+// progress, other callers of that key wait for it and share its result instead of
+// starting their own. The zero value is ready to use.
+type Group[K comparable, V any] struct {
+	mu      sync.Mutex
+	flights map[K]*flight[V]
+}
+
+// Do calls fn for key, unless a call for key is already in progress, in which case it
+// waits for that call and returns its result. shared reports whether the result was
+// delivered to more than one caller.
+//
+// fn runs with a context that keeps the values of the first caller's ctx and is only
+// cancelled once every waiting caller's ctx is done. A caller whose ctx is done stops
+// waiting and gets ctx.Err(). A panic in fn is returned to every caller as a *PanicError.
+func (g *Group[K, V]) Do(
+	ctx context.Context, key K, fn func(ctx context.Context) (V, error),
+) (v V, err error, shared bool) { //nolint:revive // mirrors x/sync/singleflight
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = make(map[K]*flight[V])
+	}
+
+	f, ok := g.flights[key]
+	if ok {
+		f.waiters++
+	} else {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight[V]{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		g.flights[key] = f
+
+		go g.call(callCtx, key, f, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		g.mu.Lock()
+		shared = f.waiters > 1
+		g.mu.Unlock()
+		return f.value, f.err, shared
+
+	case <-ctx.Done():
+		g.mu.Lock()
+		if f.waiters--; f.waiters == 0 {
+			f.cancel()
+		}
+		g.mu.Unlock()
+
+		var zero V
+		return zero, ctx.Err(), false
+	}
+}
+
+// call executes fn and publishes its result to the waiters of f.
+func (g *Group[K, V]) call(ctx context.Context, key K, f *flight[V], fn func(ctx context.Context) (V, error)) {
+	value, err := safeCall(ctx, fn)
+
+	g.mu.Lock()
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+	f.value, f.err = value, err
+	g.mu.Unlock()
+
+	f.cancel()
+	close(f.done)
+}
+
+// Forget makes the next Do for key start a new call instead of waiting for the one in
+// progress.
+func (g *Group[K, V]) Forget(key K) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.flights, key)
+}
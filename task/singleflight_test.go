@@ -0,0 +1,94 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup_Do(t *testing.T) {
+	var (
+		g       Group[string, int]
+		calls   atomic.Int32
+		release = make(chan struct{})
+		wg      sync.WaitGroup
+		shared  atomic.Int32
+	)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err, isShared := g.Do(context.Background(), "key", func(context.Context) (int, error) {
+				calls.Add(1)
+				<-release
+				return 42, nil
+			})
+			if v != 42 || err != nil {
+				t.Errorf("Expected 42, got %d, %v", v, err)
+			}
+			if isShared {
+				shared.Add(1)
+			}
+		}()
+	}
+
+	waitUntil(t, func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.flights["key"] != nil && g.flights["key"].waiters == 10
+	})
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 || shared.Load() != 10 {
+		t.Errorf("Expected 1 call shared by 10 callers, got %d calls and %d shared", calls.Load(), shared.Load())
+	}
+
+	// Once the call returned, the next Do starts a new one
+	_, _, _ = g.Do(context.Background(), "key", func(context.Context) (int, error) {
+		calls.Add(1)
+		return 0, nil
+	})
+	if calls.Load() != 2 {
+		t.Errorf("Expected a new call after the first one finished, got %d calls", calls.Load())
+	}
+}
+
+func TestGroup_Cancel(t *testing.T) {
+	var g Group[int, int]
+	callCanceled := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, err, _ := g.Do(ctx, 1, func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		close(callCanceled)
+		return 0, ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	// The call is cancelled once its only caller gave up
+	select {
+	case <-callCanceled:
+	case <-time.After(time.Second):
+		t.Errorf("Expected the call to be cancelled")
+	}
+}
+
+func TestGroup_Panic(t *testing.T) {
+	var g Group[int, int]
+	_, err, _ := g.Do(context.Background(), 1, func(context.Context) (int, error) { panic("boom") })
+
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Errorf("Expected a *PanicError, got %v", err)
+	}
+}
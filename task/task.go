@@ -2,9 +2,11 @@ package task
 
 import (
 	"context"
+	"errors"
 	"runtime"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // TaskResult holds the result of a task along with any associated error
@@ -13,22 +15,35 @@ type TaskResult struct {
 	Error  error
 }
 
-// ProcessorMetrics keeps track of key metrics for the task processor
-type ProcessorMetrics struct {
-	completedTasks uint64 // number of successfully completed tasks
-	errorCount     uint64 // number of errors encountered
-	activeWorkers  int32  // current active worker count
-}
+// TaskFunc processes one chunk of tasks. The context is cancelled when the caller's
+// context is done or the per-task timeout elapses, and implementations should return
+// promptly once it is.
+type TaskFunc func(ctx context.Context, chunk []any) (any, error)
 
 // TaskProcessor is responsible for managing task execution with a pool of workers
 type TaskProcessor struct {
-	maxWorkers int           // maximum number of concurrent workers
-	workerPool chan struct{} // worker pool semaphore to limit concurrency
+	maxWorkers int            // maximum number of concurrent workers
+	workerPool *slotScheduler // worker slots shared fairly among groups
+
+	groupWeights  map[string]int // relative share of workers per group
+	groupLimits   map[string]int // maximum concurrent workers per group
+	starvationAge time.Duration  // waiting time after which a chunk gains one priority level
+
+	taskTimeout   time.Duration   // per-task execution timeout, zero means no timeout
+	shutdownGrace time.Duration   // how long to wait for in-flight workers after cancellation, zero waits for all
+	retryPolicy   *RetryPolicy    // retry policy for failed tasks, nil disables retries
+	chunkStrategy ChunkStrategy   // how tasks are split into chunks
+	limiter       Limiter         // rate limiter for task function calls, nil disables limiting
+	breaker       *CircuitBreaker // circuit breaker for task function calls, nil disables it
+	rePanic       bool            // re-panic after a recovered task panic has been reported
 
 	errorHandler  func(error) // function to handle task errors
 	resultHandler func(any)   // function to handle task results
 
-	metrics ProcessorMetrics // processor's performance metrics
+	progress         func(Progress) // progress reporter, nil disables progress reporting
+	progressInterval time.Duration  // interval between progress reports
+
+	metrics *processorStats // processor's performance metrics
 }
 
 // ProcessorOption defines the functional option type for TaskProcessor configuration
@@ -38,28 +53,115 @@ type ProcessorOption func(*TaskProcessor)
 func NewTaskProcessor(options ...ProcessorOption) *TaskProcessor {
 	// Default worker count is the number of logical CPUs
 	processor := &TaskProcessor{
-		maxWorkers: runtime.GOMAXPROCS(0),
+		maxWorkers:    runtime.GOMAXPROCS(0),
+		groupWeights:  make(map[string]int),
+		groupLimits:   make(map[string]int),
+		starvationAge: time.Second,
+		metrics:       newProcessorStats(),
+
+		progressInterval: time.Second,
 	}
+	processor.chunkStrategy = FixedChunkCount(0) // one chunk per worker
 
 	// Apply configuration options
 	for _, option := range options {
 		option(processor)
 	}
 
-	// Initialize worker pool
-	processor.workerPool = make(chan struct{}, processor.maxWorkers)
-	for i := 0; i < processor.maxWorkers; i++ {
-		processor.workerPool <- struct{}{} // pre-fill the worker pool
+	// Count retried attempts and report them through the error handler
+	if processor.retryPolicy != nil {
+		processor.retryPolicy = processor.withRetryHooks(*processor.retryPolicy)
 	}
 
+	// At least one worker, or ProcessInChunks would wait for a slot forever
+	processor.maxWorkers = max(processor.maxWorkers, 1)
+
+	// Initialize worker pool
+	processor.workerPool = newSlotScheduler(processor.maxWorkers,
+		processor.groupWeights, processor.groupLimits, processor.starvationAge)
+
 	return processor
 }
 
-// WithMaxWorkerCount configures the maximum number of workers for the TaskProcessor
+// WithMaxWorkerCount configures the maximum number of workers for the TaskProcessor.
+// Values below 1 are treated as 1.
 func WithMaxWorkerCount(n int) ProcessorOption {
 	return func(p *TaskProcessor) { p.maxWorkers = n }
 }
 
+// WithGroupWeight configures the relative share of workers given to the named group
+// when several groups have chunks waiting. Groups default to a weight of 1.
+// Use ContextWithGroup to run ProcessInChunks on behalf of a group.
+func WithGroupWeight(group string, weight int) ProcessorOption {
+	return func(p *TaskProcessor) { p.groupWeights[group] = weight }
+}
+
+// WithGroupLimit configures the maximum number of workers the named group may use at
+// once. Zero, the default, means the group is only bounded by the worker count.
+func WithGroupLimit(group string, n int) ProcessorOption {
+	return func(p *TaskProcessor) { p.groupLimits[group] = n }
+}
+
+// WithStarvationTimeout configures how long a chunk may wait for a worker before it is
+// promoted by one priority level, and again for every further period. It keeps low
+// priority work from starving. The default is one second, zero disables promotion.
+func WithStarvationTimeout(d time.Duration) ProcessorOption {
+	return func(p *TaskProcessor) { p.starvationAge = d }
+}
+
+// WithTaskTimeout configures the maximum execution time of a single task function call.
+// The context passed to the task function is cancelled once d elapses.
+func WithTaskTimeout(d time.Duration) ProcessorOption {
+	return func(p *TaskProcessor) { p.taskTimeout = d }
+}
+
+// WithShutdownGrace configures how long ProcessInChunks waits for in-flight workers to
+// stop after its context is cancelled. Workers still running when the grace period ends
+// are abandoned: they keep their worker slot until they return, but ProcessInChunks no
+// longer waits for them. By default ProcessInChunks waits for every in-flight worker.
+func WithShutdownGrace(d time.Duration) ProcessorOption {
+	return func(p *TaskProcessor) { p.shutdownGrace = d }
+}
+
+// WithRetry configures the retry policy for failed task function calls. Every failed
+// attempt that is retried is reported to the error handler as an *AttemptError, while
+// only the final failure is counted as an error.
+func WithRetry(policy RetryPolicy) ProcessorOption {
+	return func(p *TaskProcessor) { p.retryPolicy = &policy }
+}
+
+// WithChunkStrategy configures how ProcessInChunks splits tasks into chunks.
+// By default tasks are split into one chunk per worker.
+func WithChunkStrategy(strategy ChunkStrategy) ProcessorOption {
+	return func(p *TaskProcessor) { p.chunkStrategy = strategy }
+}
+
+// WithRateLimit limits task function calls to rps per second on average with bursts of
+// up to burst calls, using a token bucket owned by the processor.
+func WithRateLimit(rps float64, burst int) ProcessorOption {
+	return func(p *TaskProcessor) { p.limiter = NewTokenBucket(rps, burst) }
+}
+
+// WithLimiter configures a rate limiter for task function calls. The limiter may be
+// shared with other processors to enforce a common limit.
+func WithLimiter(limiter Limiter) ProcessorOption {
+	return func(p *TaskProcessor) { p.limiter = limiter }
+}
+
+// WithCircuitBreaker calls the task function through breaker, so chunks fail fast with
+// ErrCircuitOpen while the downstream is failing instead of hammering it. The breaker
+// may be shared with other processors that call the same downstream.
+func WithCircuitBreaker(breaker *CircuitBreaker) ProcessorOption {
+	return func(p *TaskProcessor) { p.breaker = breaker }
+}
+
+// WithRePanic makes workers panic again with the *PanicError after a recovered task
+// panic has been counted and passed to the error handler. It is meant for tests, where
+// a panicking task should crash loudly instead of being reported as an error.
+func WithRePanic(enabled bool) ProcessorOption {
+	return func(p *TaskProcessor) { p.rePanic = enabled }
+}
+
 // WithErrorHandler configures the error handler for tasks that encounter errors
 func WithErrorHandler(handler func(error)) ProcessorOption {
 	return func(p *TaskProcessor) { p.errorHandler = handler }
@@ -70,99 +172,287 @@ func WithResultHandler(handler func(any)) ProcessorOption {
 	return func(p *TaskProcessor) { p.resultHandler = handler }
 }
 
-// ProcessInChunks processes tasks in chunks, distributing them among workers
-func (p *TaskProcessor) ProcessInChunks(ctx context.Context, tasks []any, taskFunc func([]any) (any, error)) error {
+// WithProgress configures a function that receives progress reports while
+// ProcessInChunks runs, and a final one when it returns. Reports are delivered one at
+// a time from a single goroutine. See NewProgressBar and LogProgress.
+func WithProgress(report func(Progress)) ProcessorOption {
+	return func(p *TaskProcessor) { p.progress = report }
+}
+
+// WithProgressInterval configures the interval between progress reports, one second by default
+func WithProgressInterval(d time.Duration) ProcessorOption {
+	return func(p *TaskProcessor) {
+		if d > 0 {
+			p.progressInterval = d
+		}
+	}
+}
+
+// ProcessInChunks processes tasks in chunks, distributing them among workers.
+//
+// Chunks wait for a free worker according to the group and priority attached to ctx
+// with ContextWithGroup and ContextWithPriority: higher priorities go first, and groups
+// of equal priority share workers in proportion to their weights.
+//
+// Every task function receives a context derived from ctx. When ctx is cancelled no
+// further chunks are dispatched, in-flight workers observe the cancellation through
+// their context, and ProcessInChunks returns ctx.Err() only after those workers have
+// stopped or the shutdown grace period has elapsed.
+func (p *TaskProcessor) ProcessInChunks(ctx context.Context, tasks []any, taskFunc TaskFunc) error {
 	if len(tasks) == 0 {
 		return nil // no tasks to process
 	}
 
-	// Determine chunk size based on the number of workers
-	chunkSize := (len(tasks) + p.maxWorkers - 1) / p.maxWorkers // ceiling division
-	taskChunks := make([][]any, 0, p.maxWorkers)
+	// Workers run under a derived context so they can be stopped on cancellation
+	workCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	// Split tasks into chunks
-	for i := 0; i < len(tasks); i += chunkSize {
-		end := i + chunkSize
-		if end > len(tasks) {
-			end = len(tasks)
-		}
-		taskChunks = append(taskChunks, tasks[i:end])
+	var (
+		wg       sync.WaitGroup
+		progress *progressTracker
+	)
+	group, priority := groupFromContext(ctx)
+	if d, ok := p.chunkStrategy.(dynamicChunks); ok {
+		progress = p.startProgress(len(tasks), (len(tasks)+d.size-1)/d.size)
+		p.dispatchDynamic(workCtx, &wg, group, priority, tasks, d.size, taskFunc, progress)
+	} else {
+		taskChunks := p.chunkStrategy.Split(tasks, p.maxWorkers)
+		progress = p.startProgress(len(tasks), len(taskChunks))
+		p.dispatchChunks(ctx, workCtx, &wg, group, priority, taskChunks, taskFunc, progress)
 	}
+	defer progress.stop() // final report once the workers are done
 
-	// Error and result channels for handling task outcomes
-	errorChan := make(chan error, len(taskChunks))
-	resultChan := make(chan TaskResult, len(taskChunks))
+	// Wait for all workers to complete
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()   // wait for all goroutines to finish
+		close(done) // signal completion
+	}()
+
+	// Wait for either all tasks to complete or context cancellation
+	select {
+	case <-ctx.Done():
+	case <-done:
+		return nil
+	}
+
+	// Stop in-flight workers and wait for them as configured
+	cancel()
+	p.awaitWorkers(done)
+	return ctx.Err()
+}
 
-	var wg sync.WaitGroup
-	wg.Add(len(taskChunks)) // each chunk is processed by one worker
+// dispatchChunks hands each chunk to its own goroutine as soon as a worker slot is free.
+// It stops dispatching once ctx is done.
+func (p *TaskProcessor) dispatchChunks(ctx, workCtx context.Context, wg *sync.WaitGroup,
+	group string, priority Priority, taskChunks [][]any, taskFunc TaskFunc, progress *progressTracker,
+) {
+	// Chunks wait in the queue until a worker picks them up
+	pending := int64(len(taskChunks))
+	p.metrics.queueDepth.Add(pending)
+	defer func() { p.metrics.queueDepth.Add(-pending) }()
 
 	// Process each task chunk with a worker
 	for i := range taskChunks {
-		select {
-		case <-ctx.Done():
-			return ctx.Err() // context canceled, exit early
-
-		case <-p.workerPool:
-			// Launch a goroutine to handle this chunk
-			go func(chunk []any) {
-				defer func() {
-					p.workerPool <- struct{}{} // release worker slot back to the pool
-					wg.Done()                  // mark this worker as done
-				}()
-
-				// Track active workers
-				atomic.AddInt32(&p.metrics.activeWorkers, 1)
-				defer atomic.AddInt32(&p.metrics.activeWorkers, -1)
-
-				// Execute the task function for this chunk
-				result, err := taskFunc(chunk)
-				if err != nil {
-					// Track error count and invoke error handler
-					atomic.AddUint64(&p.metrics.errorCount, 1)
-					if p.errorHandler != nil {
-						p.errorHandler(err)
-					}
-					errorChan <- err
-					return
+		if err := p.workerPool.acquire(ctx, group, priority); err != nil {
+			return // context canceled, stop dispatching
+		}
+
+		wg.Add(1)
+		pending--
+		p.metrics.queueDepth.Add(-1)
+		p.metrics.chunksInFlight.Add(1)
+
+		// Launch a goroutine to handle this chunk
+		go func(chunk []any) {
+			defer func() {
+				p.metrics.chunksInFlight.Add(-1)
+				p.workerPool.release(group) // release worker slot back to the pool
+				wg.Done()                   // mark this worker as done
+			}()
+
+			p.runChunk(workCtx, chunk, taskFunc, progress)
+		}(taskChunks[i])
+	}
+}
+
+// dispatchDynamic starts up to maxWorkers goroutines that pull chunks of size tasks
+// from a shared cursor until all tasks are claimed or workCtx is done. Every chunk is
+// run under its own worker slot so that other groups can interleave.
+func (p *TaskProcessor) dispatchDynamic(workCtx context.Context, wg *sync.WaitGroup,
+	group string, priority Priority, tasks []any, size int, taskFunc TaskFunc, progress *progressTracker,
+) {
+	numChunks := (len(tasks) + size - 1) / size
+	p.metrics.queueDepth.Add(int64(numChunks))
+
+	var (
+		cursor  atomic.Int64 // index of the next unclaimed chunk
+		claimed atomic.Int64 // chunks taken off the queue
+		workers atomic.Int32 // live workers
+	)
+
+	workerCount := min(p.maxWorkers, numChunks)
+	workers.Store(int32(workerCount))
+	wg.Add(workerCount)
+
+	for range workerCount {
+		go func() {
+			defer func() {
+				// The last one out removes the chunks that were never claimed from the queue depth
+				if workers.Add(-1) == 0 {
+					p.metrics.queueDepth.Add(claimed.Load() - int64(numChunks))
 				}
+				wg.Done() // mark this worker as done
+			}()
 
-				// Track completed tasks and invoke result handler
-				atomic.AddUint64(&p.metrics.completedTasks, 1)
-				if p.resultHandler != nil {
-					p.resultHandler(result)
+			for int(cursor.Load()) < numChunks {
+				if err := p.workerPool.acquire(workCtx, group, priority); err != nil {
+					return // context canceled
 				}
-				resultChan <- TaskResult{
-					Result: result,
-					Error:  nil,
+
+				i := int(cursor.Add(1) - 1)
+				if i >= numChunks {
+					p.workerPool.release(group)
+					return // all chunks claimed
 				}
-			}(taskChunks[i])
+				claimed.Add(1)
+				p.metrics.queueDepth.Add(-1)
+				p.metrics.chunksInFlight.Add(1)
+
+				end := min((i+1)*size, len(tasks))
+				p.runChunk(workCtx, tasks[i*size:end], taskFunc, progress)
+
+				p.metrics.chunksInFlight.Add(-1)
+				p.workerPool.release(group) // release worker slot back to the pool
+			}
+		}()
+	}
+}
+
+// runChunk executes the task function for a single chunk and records the outcome.
+func (p *TaskProcessor) runChunk(ctx context.Context, chunk []any, taskFunc TaskFunc, progress *progressTracker) {
+	_, _ = p.execute(ctx, len(chunk), func(ctx context.Context) (any, error) {
+		return taskFunc(ctx, chunk)
+	}, progress)
+}
+
+// execute runs fn, which processes the given number of items, with the processor's
+// rate limit, circuit breaker, timeout, panic recovery and retry policy, and records
+// the outcome in the metrics and handlers.
+func (p *TaskProcessor) execute(
+	ctx context.Context, items int, fn func(ctx context.Context) (any, error), progress *progressTracker,
+) (any, error) {
+	// Track active workers
+	p.metrics.activeWorkers.Add(1)
+	defer p.metrics.activeWorkers.Add(-1)
+
+	// Execute the task function for this chunk, the circuit breaker, rate limit and
+	// timeout apply to every attempt
+	call := func(ctx context.Context) (result any, err error) {
+		if err := p.throttle(ctx); err != nil {
+			return nil, err
 		}
+		if p.breaker != nil {
+			done, allowErr := p.breaker.Allow()
+			if allowErr != nil {
+				return nil, allowErr
+			}
+			defer func() { done(err) }() // err is the outcome of the task function
+		}
+
+		if p.taskTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, p.taskTimeout)
+			defer cancel()
+		}
+
+		// A panicking task must not take down the process or leak its worker slot
+		return safeCall(ctx, fn)
 	}
 
-	// Wait for all workers to complete
-	done := make(chan struct{})
-	go func() {
-		wg.Wait()         // wait for all goroutines to finish
-		close(done)       // signal completion
-		close(errorChan)  // close error channel
-		close(resultChan) // close result channel
-	}()
+	var (
+		result any
+		err    error
+		start  = time.Now()
+	)
+	if p.retryPolicy != nil {
+		// A panic is a bug rather than a transient failure: report it once, unretried
+		result, err = Retry(ctx, func(ctx context.Context) (any, error) {
+			result, err := call(ctx)
+			var panicErr *PanicError
+			if errors.As(err, &panicErr) {
+				return result, Permanent(err)
+			}
+			return result, err
+		}, *p.retryPolicy)
+	} else {
+		result, err = call(ctx)
+	}
+	p.metrics.latency.observe(time.Since(start))
+	progress.chunkDone(items, err != nil)
 
-	// Wait for either all tasks to complete or context cancellation
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
+	if err != nil {
+		// Track error count and invoke error handler
+		p.metrics.errorCount.Add(1)
+		if p.errorHandler != nil {
+			p.errorHandler(err)
+		}
 
-	case <-done:
+		var panicErr *PanicError
+		if p.rePanic && errors.As(err, &panicErr) {
+			panic(panicErr)
+		}
+		return nil, err
+	}
+
+	// Track completed tasks and invoke result handler
+	p.metrics.completedTasks.Add(1)
+	p.metrics.processedItems.Add(uint64(items))
+	if p.resultHandler != nil {
+		p.resultHandler(result)
+	}
+	return result, nil
+}
+
+// throttle waits for the rate limiter, if any, and records the time spent waiting.
+func (p *TaskProcessor) throttle(ctx context.Context) error {
+	if p.limiter == nil {
 		return nil
 	}
+
+	start := time.Now()
+	err := p.limiter.Wait(ctx)
+	p.metrics.throttledNanos.Add(int64(time.Since(start)))
+	return err
+}
+
+// withRetryHooks returns a copy of policy that also records retried attempts.
+func (p *TaskProcessor) withRetryHooks(policy RetryPolicy) *RetryPolicy {
+	onRetry := policy.OnRetry
+	policy.OnRetry = func(attempt int, err error, delay time.Duration) {
+		p.metrics.retryCount.Add(1)
+		if p.errorHandler != nil {
+			p.errorHandler(&AttemptError{Attempt: attempt, Delay: delay, Err: err})
+		}
+		if onRetry != nil {
+			onRetry(attempt, err, delay)
+		}
+	}
+	return &policy
 }
 
-// Metrics returns the current processor's performance metrics
-func (p *TaskProcessor) Metrics() ProcessorMetrics {
-	return ProcessorMetrics{
-		activeWorkers:  atomic.LoadInt32(&p.metrics.activeWorkers),
-		completedTasks: atomic.LoadUint64(&p.metrics.completedTasks),
-		errorCount:     atomic.LoadUint64(&p.metrics.errorCount),
+// awaitWorkers blocks until done is closed or the shutdown grace period elapses.
+func (p *TaskProcessor) awaitWorkers(done <-chan struct{}) {
+	if p.shutdownGrace <= 0 {
+		<-done
+		return
+	}
+
+	timer := time.NewTimer(p.shutdownGrace)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
 	}
 }
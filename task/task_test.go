@@ -2,7 +2,9 @@ package task
 
 import (
 	"context"
+	"errors"
 	"runtime"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -32,7 +34,7 @@ func TestTaskProcessor(t *testing.T) {
 	defer cancel()
 
 	err := processor.ProcessInChunks(ctx, vData,
-		func(data []any) (any, error) {
+		func(_ context.Context, data []any) (any, error) {
 			result := make([]any, 0, len(data))
 
 			for i := range data {
@@ -50,7 +52,7 @@ func TestTaskProcessor(t *testing.T) {
 	}
 
 	stats := processor.Metrics()
-	log.Infof("Porcessing completed. Completed tasks: %d, Errors: %d", stats.completedTasks, stats.errorCount)
+	log.Infof("Porcessing completed. Completed tasks: %d, Errors: %d", stats.CompletedTasks, stats.ErrorCount)
 }
 
 func TestTaskProcess_DataCut(t *testing.T) {
@@ -76,7 +78,7 @@ func TestTaskProcess_DataCut(t *testing.T) {
 	defer cancel()
 
 	err := processor.ProcessInChunks(ctx, vData,
-		func(data []any) (any, error) {
+		func(_ context.Context, data []any) (any, error) {
 			return len(data), nil
 		})
 	if err != nil {
@@ -86,7 +88,82 @@ func TestTaskProcess_DataCut(t *testing.T) {
 	stats := processor.Metrics()
 	log.Infof(
 		"Porcessing completed. Completed tasks: %d, Errors: %d",
-		stats.completedTasks,
-		stats.errorCount,
+		stats.CompletedTasks,
+		stats.ErrorCount,
 	)
 }
+
+func TestTaskProcessor_CancelWaitsForWorkers(t *testing.T) {
+	processor := NewTaskProcessor(WithMaxWorkerCount(4))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var running, stopped atomic.Int32
+
+	go func() {
+		for running.Load() < 4 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+
+	err := processor.ProcessInChunks(ctx, []any{1, 2, 3, 4},
+		func(ctx context.Context, _ []any) (any, error) {
+			running.Add(1)
+			<-ctx.Done()
+			time.Sleep(10 * time.Millisecond)
+			stopped.Add(1)
+			return nil, ctx.Err()
+		})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if n := stopped.Load(); n != 4 {
+		t.Errorf("Expected all 4 workers stopped before return, got %d", n)
+	}
+}
+
+func TestTaskProcessor_TaskTimeout(t *testing.T) {
+	processor := NewTaskProcessor(
+		WithMaxWorkerCount(2),
+		WithTaskTimeout(20*time.Millisecond),
+	)
+
+	err := processor.ProcessInChunks(context.Background(), []any{1, 2},
+		func(ctx context.Context, _ []any) (any, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+	if err != nil {
+		t.Fatalf("ProcessInChunks failed: %v", err)
+	}
+
+	if stats := processor.Metrics(); stats.ErrorCount != 2 {
+		t.Errorf("Expected 2 timed out tasks, got %d", stats.ErrorCount)
+	}
+}
+
+func TestTaskProcessor_ShutdownGrace(t *testing.T) {
+	processor := NewTaskProcessor(
+		WithMaxWorkerCount(1),
+		WithShutdownGrace(20*time.Millisecond),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	start := time.Now()
+	err := processor.ProcessInChunks(ctx, []any{1},
+		func(_ context.Context, _ []any) (any, error) {
+			<-release // ignores cancellation on purpose
+			return nil, nil
+		})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected worker to be abandoned, waited %v", elapsed)
+	}
+}